@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cacheCounter counts lookups in one cache. Atomics keep it off the
+// caches' own locks.
+type cacheCounter struct {
+	hits, misses atomic.Int64
+}
+
+func (c *cacheCounter) record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// CacheStats describes one cache for /admin/cache
+type CacheStats struct {
+	Name    string     `json:"name"`
+	Entries int        `json:"entries"`
+	Hits    int64      `json:"hits"`
+	Misses  int64      `json:"misses"`
+	Oldest  *time.Time `json:"oldest,omitempty"` // When the oldest entry was stored; absent when empty
+}
+
+// adminCache is a cache operators can inspect and flush
+type adminCache struct {
+	counter *cacheCounter
+	// entries returns the entry count and the oldest entry's time
+	entries func() (int, time.Time)
+	// clear empties the cache and returns how many entries it held
+	clear func() int
+}
+
+// adminCaches are the process's caches by name. Hit/miss counters survive
+// a flush; they count since startup.
+var adminCaches = map[string]adminCache{
+	"scans": {
+		counter: &scanCacheCounter,
+		entries: func() (int, time.Time) {
+			scanCache.Lock()
+			defer scanCache.Unlock()
+			var oldest time.Time
+			for _, c := range scanCache.entries {
+				if oldest.IsZero() || c.at.Before(oldest) {
+					oldest = c.at
+				}
+			}
+			return len(scanCache.entries), oldest
+		},
+		clear: func() int {
+			scanCache.Lock()
+			defer scanCache.Unlock()
+			n := len(scanCache.entries)
+			scanCache.entries = make(map[string]cachedScan)
+			return n
+		},
+	},
+	"robots": {
+		counter: &robotsCacheCounter,
+		entries: func() (int, time.Time) {
+			robotsCache.mu.Lock()
+			defer robotsCache.mu.Unlock()
+			var oldest time.Time
+			for _, e := range robotsCache.entries {
+				if oldest.IsZero() || e.fetched.Before(oldest) {
+					oldest = e.fetched
+				}
+			}
+			return len(robotsCache.entries), oldest
+		},
+		clear: func() int {
+			robotsCache.mu.Lock()
+			defer robotsCache.mu.Unlock()
+			n := len(robotsCache.entries)
+			robotsCache.entries = make(map[string]robotsEntry)
+			return n
+		},
+	},
+	"get-only-hosts": {
+		counter: &getOnlyHosts.counter,
+		entries: getOnlyHosts.stats,
+		clear:   getOnlyHosts.clear,
+	},
+}
+
+// adminCacheNames returns the cache names, sorted
+func adminCacheNames() []string {
+	names := make([]string, 0, len(adminCaches))
+	for name := range adminCaches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AdminCacheHandler handles /admin/cache. GET lists every cache's size,
+// hit/miss counts and oldest entry; POST ?name=xxx empties one cache
+// (name=all empties them all). Requests need "Authorization: Bearer
+// <Config.AdminToken>"; without a configured token the endpoint doesn't
+// exist.
+func AdminCacheHandler(w http.ResponseWriter, r *http.Request) {
+	token := currentConfig().AdminToken
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !secretEqual(given, token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		stats := make([]CacheStats, 0, len(adminCaches))
+		for _, name := range adminCacheNames() {
+			c := adminCaches[name]
+			n, oldest := c.entries()
+			s := CacheStats{Name: name, Entries: n, Hits: c.counter.hits.Load(), Misses: c.counter.misses.Load()}
+			if !oldest.IsZero() {
+				s.Oldest = &oldest
+			}
+			stats = append(stats, s)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+
+	case http.MethodPost:
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		names := []string{name}
+		if name == "all" {
+			names = adminCacheNames()
+		} else if _, ok := adminCaches[name]; !ok {
+			http.Error(w, fmt.Sprintf("unknown cache %q (want all or one of %s)", name, strings.Join(adminCacheNames(), ", ")), http.StatusBadRequest)
+			return
+		}
+		cleared := make(map[string]int, len(names))
+		for _, n := range names {
+			cleared[n] = adminCaches[n].clear()
+		}
+		logFor(r.Context(), "admin").Info("flushed caches", "cleared", cleared)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"cleared": cleared})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
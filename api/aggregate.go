@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Result orders accepted by Config.ResultOrder
+const (
+	ResultOrderURL        = "url"        // Alphabetical by URL, the default
+	ResultOrderCitation   = "citation"   // By the first reference citing each URL, as in the article
+	ResultOrderCompletion = "completion" // In the order the checks finished
+)
+
+// ParseResultOrder validates a Config.ResultOrder value; empty means
+// ResultOrderURL
+func ParseResultOrder(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return ResultOrderURL, nil
+	case ResultOrderURL, ResultOrderCitation, ResultOrderCompletion:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown result order %q (want %q, %q or %q)", s, ResultOrderURL, ResultOrderCitation, ResultOrderCompletion)
+}
+
+// resultAggregator collects the results of concurrent link checks. Each
+// result lands in the slot of its URL, so workers never share a slice
+// position, and collect returns the completed ones in the configured
+// order, whether or not every check ran.
+type resultAggregator struct {
+	mu      sync.Mutex
+	order   string
+	results []linkResult
+	done    []int // Completion sequence per slot, 1-based; 0 while pending
+	checked int
+}
+
+func newResultAggregator(total int, order string) *resultAggregator {
+	return &resultAggregator{order: order, results: make([]linkResult, total), done: make([]int, total)}
+}
+
+// add stores the result for slot i and returns how many results are in
+func (a *resultAggregator) add(i int, lr linkResult) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done[i] == 0 {
+		a.checked++
+		a.done[i] = a.checked
+	}
+	a.results[i] = lr
+	return a.checked
+}
+
+// collect returns the completed results. Slots are in URL order to begin
+// with; citation order sorts by first citation number (uncited URLs last)
+// and completion order by when each finished.
+func (a *resultAggregator) collect() []linkResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	slots := make([]int, 0, a.checked)
+	for i, seq := range a.done {
+		if seq > 0 {
+			slots = append(slots, i)
+		}
+	}
+	switch a.order {
+	case ResultOrderCitation:
+		first := func(i int) int {
+			nums := a.results[i].CitationNumbers
+			if len(nums) == 0 {
+				return int(^uint(0) >> 1)
+			}
+			m := nums[0]
+			for _, n := range nums[1:] {
+				m = min(m, n)
+			}
+			return m
+		}
+		sort.SliceStable(slots, func(x, y int) bool { return first(slots[x]) < first(slots[y]) })
+	case ResultOrderCompletion:
+		sort.Slice(slots, func(x, y int) bool { return a.done[slots[x]] < a.done[slots[y]] })
+	}
+
+	out := make([]linkResult, len(slots))
+	for k, i := range slots {
+		out[k] = a.results[i]
+	}
+	return out
+}
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// archiveBatchRetention is how long a batch stays queryable after it
+	// was started; long enough for a nightly job to check the last run
+	archiveBatchRetention = 36 * time.Hour
+	// archiveBatchFollowLimit bounds how long a batch keeps copying its
+	// jobs' states out of the SPN job store
+	archiveBatchFollowLimit = 6 * time.Hour
+	// archiveMaxBatches bounds the batch store; the oldest go first
+	archiveMaxBatches = 200
+)
+
+// Archive-page batch states
+const (
+	archiveBatchScanning  = "scanning"  // The page scan is running
+	archiveBatchSubmitted = "submitted" // Links are queued or being captured
+	archiveBatchDone      = "done"      // Every job has finished
+	archiveBatchError     = "error"     // The scan failed; nothing was submitted
+)
+
+// ArchivePageBatch is the response of /api/archive-page and
+// /api/archive-page/status: one page's unarchived links on their way
+// through the SPN queue
+type ArchivePageBatch struct {
+	BatchID string    `json:"batch_id"`
+	Page    string    `json:"page"`
+	State   string    `json:"state"` // "scanning", "submitted", "done" or "error"
+	Started time.Time `json:"started"`
+	Error   string    `json:"error,omitempty"`
+	Partial bool      `json:"partial,omitempty"` // The scan ran out of time; only the links it checked were submitted
+
+	Links     int `json:"links"`   // Links the scan found
+	Total     int `json:"total"`   // Links needing an archive
+	Pending   int `json:"pending"` // Queued, submitting or being captured
+	Done      int `json:"done"`    // Captured, already archived or unchanged
+	Failed    int `json:"failed"`
+	Unchanged int `json:"unchanged,omitempty"` // Not submitted: same content as the latest capture (skip_unchanged)
+
+	Jobs []SPNJob `json:"jobs,omitempty"` // Only on /api/archive-page/status
+}
+
+// archiveBatch is a stored batch. jobs keeps the last state seen of each
+// queued job, so counts survive the job store evicting finished jobs;
+// unchanged holds the links that were never queued.
+type archiveBatch struct {
+	ArchivePageBatch
+	queueIDs  []string
+	jobs      map[string]SPNJob
+	unchanged []SPNJob
+}
+
+// archiveBatchStore holds the batches started by /api/archive-page
+type archiveBatchStore struct {
+	mu      sync.Mutex
+	batches map[string]*archiveBatch
+}
+
+var archiveBatches = &archiveBatchStore{batches: make(map[string]*archiveBatch)}
+
+// add stores a new batch for page and returns its ID
+func (s *archiveBatchStore) add(page string) string {
+	b := &archiveBatch{
+		ArchivePageBatch: ArchivePageBatch{BatchID: newQueueID(), Page: page, State: archiveBatchScanning, Started: time.Now().UTC()},
+		jobs:             make(map[string]SPNJob),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	s.batches[b.BatchID] = b
+	return b.BatchID
+}
+
+// update runs fn on a stored batch under the lock
+func (s *archiveBatchStore) update(id string, fn func(b *archiveBatch)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.batches[id]; ok {
+		fn(b)
+	}
+}
+
+// get returns a batch with its jobs' current states and counts. ok is
+// false for unknown or expired batches.
+func (s *archiveBatchStore) get(id string) (ArchivePageBatch, bool) {
+	s.mu.Lock()
+	b, ok := s.batches[id]
+	var ids []string
+	if ok {
+		ids = append(ids, b.queueIDs...)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return ArchivePageBatch{}, false
+	}
+
+	// spnJobs has its own lock; read it without holding ours
+	fresh := make(map[string]SPNJob, len(ids))
+	for _, qid := range ids {
+		if job, ok := spnJobs.get(qid); ok {
+			fresh[qid] = job
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for qid, job := range fresh {
+		b.jobs[qid] = job
+	}
+	out := b.ArchivePageBatch
+	out.Pending, out.Done, out.Failed = 0, 0, 0
+	jobs := make([]SPNJob, 0, len(b.queueIDs)+len(b.unchanged))
+	for _, qid := range b.queueIDs {
+		jobs = append(jobs, b.jobs[qid])
+	}
+	out.Jobs = make([]SPNJob, 0, len(jobs)+len(b.unchanged))
+	for _, job := range append(jobs, b.unchanged...) {
+		switch {
+		case job.Status == "error":
+			out.Failed++
+		case IsTerminal(job.Status):
+			out.Done++
+		default:
+			out.Pending++
+		}
+		out.Jobs = append(out.Jobs, job)
+	}
+	if out.State == archiveBatchSubmitted && out.Pending == 0 {
+		out.State = archiveBatchDone
+		b.State = archiveBatchDone
+	}
+	return out, true
+}
+
+// evictLocked drops expired batches, then the oldest while the store is
+// full. Caller holds s.mu.
+func (s *archiveBatchStore) evictLocked() {
+	for id, b := range s.batches {
+		if time.Since(b.Started) > archiveBatchRetention {
+			delete(s.batches, id)
+		}
+	}
+	if len(s.batches) < archiveMaxBatches {
+		return
+	}
+	ids := make([]string, 0, len(s.batches))
+	for id := range s.batches {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.batches[ids[i]].Started.Before(s.batches[ids[j]].Started) })
+	for _, id := range ids[:len(ids)-archiveMaxBatches+1] {
+		delete(s.batches, id)
+	}
+}
+
+// runArchiveBatch scans page, queues its unarchived links for SPN and
+// follows the jobs until they finish, keeping the batch up to date. It
+// runs detached from the request that started it.
+func runArchiveBatch(id, page string, req ScanArchiveRequest) {
+	ctx := withLogAttrs(withScanID(context.Background()), "batch_id", id)
+	logger := logFor(ctx, "archive_page").With("page", page)
+
+	// A scan cut short by its time limit still submits the links it got
+	// to; big pages, the ones most worth archiving, are the ones that time out
+	results, _, err := scanPage(ctx, page)
+	_, partial := asPartialScan(err)
+	if partial {
+		logger.Warn("scan incomplete, submitting the links it checked", "error", err)
+	} else if err != nil {
+		logger.Warn("scan failed, nothing submitted", "error", err)
+		archiveBatches.update(id, func(b *archiveBatch) {
+			b.State, b.Error = archiveBatchError, err.Error()
+		})
+		return
+	}
+
+	// The queue spaces submissions out at the SPN rate limit
+	cfg := currentConfig()
+	var queueIDs []string
+	var unchanged []SPNJob
+	for _, lr := range results {
+		if !needsArchive(lr, req.RecaptureStale) {
+			continue
+		}
+		target := archiveTarget(lr, cfg)
+		if req.SkipUnchanged {
+			if job, ok := unchangedSnapshot(ctx, target); ok {
+				unchanged = append(unchanged, job)
+				continue
+			}
+		}
+		job := spnJobs.enqueue(target, req.AccessKey, req.SecretKey, req.Options)
+		queueIDs = append(queueIDs, job.QueueID)
+	}
+	archiveBatches.update(id, func(b *archiveBatch) {
+		b.State, b.Links, b.queueIDs, b.unchanged = archiveBatchSubmitted, len(results), queueIDs, unchanged
+		b.Total, b.Unchanged = len(queueIDs)+len(unchanged), len(unchanged)
+		if partial {
+			b.Partial, b.Error = true, err.Error()
+		}
+	})
+	logger.Info("queued unarchived links", "links", len(results), "submitted", len(queueIDs), "unchanged", len(unchanged))
+
+	// Copy job states out of the job store while they are still there
+	deadline := time.Now().Add(archiveBatchFollowLimit)
+	ticker := time.NewTicker(spnPollInterval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		b, ok := archiveBatches.get(id)
+		if !ok || b.State != archiveBatchSubmitted {
+			if ok {
+				logger.Info("batch finished", "done", b.Done, "failed", b.Failed)
+			}
+			return
+		}
+		<-ticker.C
+	}
+	logger.Warn("stopped following batch", "after", archiveBatchFollowLimit)
+}
+
+// ArchivePageHandler handles POST /api/archive-page?page=xxx, the headless
+// form of /api/scan-and-archive for scheduled jobs. It answers 202 at once
+// with a batch ID; the scan and SPN submissions of every unarchived,
+// reachable link then run in the background, through the same rate-limited
+// queue as /api/spn/submit. The optional body and the credential fallback
+// are those of /api/scan-and-archive; there is no batch limit.
+func ArchivePageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req ScanArchiveRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.AccessKey == "" || req.SecretKey == "" {
+		req.AccessKey = os.Getenv("IA_ACCESS_KEY")
+		req.SecretKey = os.Getenv("IA_SECRET_KEY")
+	}
+	if req.AccessKey == "" || req.SecretKey == "" {
+		http.Error(w, "Credentials required", http.StatusBadRequest)
+		return
+	}
+	if err := req.Options.validate(); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := archiveBatches.add(page)
+	go runArchiveBatch(id, page, req)
+	logFor(r.Context(), "archive_page").Info("started batch", "page", page, "batch_id", id)
+
+	batch, _ := archiveBatches.get(id)
+	batch.Jobs = nil
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/archive-page/status?batch="+id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// ArchivePageStatusHandler handles GET /api/archive-page/status?batch=xxx,
+// reporting how many of a batch's jobs are pending, done or failed, with
+// each job's current state
+func ArchivePageStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("batch"))
+	if id == "" {
+		http.Error(w, "batch required", http.StatusBadRequest)
+		return
+	}
+	batch, ok := archiveBatches.get(id)
+	if !ok {
+		http.Error(w, "batch not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
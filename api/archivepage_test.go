@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunArchiveBatchPartialScan(t *testing.T) {
+	var base string
+	cfg := testConfig()
+	cfg.Workers = 1
+	cfg.ScanTimeout = 300 * time.Millisecond
+	srv := stubUpstream(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/w/api.php":
+			// Checked in URL order: /fast, then /slow eats the scan budget
+			// and /zzz is never reached
+			wikitext := "<ref>" + base + "/fast</ref><ref>" + base + "/slow</ref><ref>" + base + "/zzz</ref>"
+			json.NewEncoder(w).Encode(map[string]any{
+				"parse": map[string]any{"title": "Big page", "revid": 1, "wikitext": map[string]string{"*": wikitext}},
+			})
+		case r.URL.Path == "/wayback/available":
+			w.Write([]byte(`{"archived_snapshots": {}}`))
+		case r.URL.Path == "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case r.URL.Path == "/fast", r.URL.Path == "/zzz":
+		default:
+			// SPN submissions from the queue
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	base = srv.URL
+
+	id := archiveBatches.add("Big_page")
+	go runArchiveBatch(id, "Big_page", ScanArchiveRequest{AccessKey: "a", SecretKey: "s"})
+
+	// Wait for the submission to fail too, so the queue is idle before the
+	// stub goes away
+	var b ArchivePageBatch
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		b, _ = archiveBatches.get(id)
+		if b.State == archiveBatchDone || b.State == archiveBatchError || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if b.State != archiveBatchDone {
+		t.Fatalf("batch state = %q (%s); want the checked links submitted", b.State, b.Error)
+	}
+	if !b.Partial || !strings.Contains(b.Error, "timed out") {
+		t.Errorf("partial = %v, error = %q; want a partial batch that timed out", b.Partial, b.Error)
+	}
+	if b.Links != 2 {
+		t.Errorf("links = %d; want the 2 the scan checked", b.Links)
+	}
+	if len(b.Jobs) != 1 || b.Jobs[0].URL != base+"/fast" {
+		t.Errorf("jobs = %+v; want only %s/fast submitted", b.Jobs, base)
+	}
+}
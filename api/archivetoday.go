@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveTodayTimemap lists every archive.today capture of a URL in
+// application/link-format, the same syntax as a Memento Link header
+const archiveTodayTimemap = "https://archive.ph/timemap/"
+
+// archiveTodayCooldown is how long lookups pause after archive.today
+// answers 429 without a Retry-After
+const archiveTodayCooldown = time.Minute
+
+// errArchiveTodayRateLimited is returned while archive.today is throttling us
+var errArchiveTodayRateLimited = errors.New("archive.today rate limited")
+
+// archiveTodayPause holds lookups back after a 429, so a scan doesn't keep
+// hammering archive.today while it is throttling
+var archiveTodayPause struct {
+	sync.Mutex
+	until time.Time
+}
+
+// ArchiveTodayProvider looks up captures on archive.today (archive.ph,
+// archive.is, ...) through its timemap. Add it to Config.ArchiveProviders
+// after WaybackProvider to fall back to it when the Internet Archive has
+// nothing.
+type ArchiveTodayProvider struct{}
+
+// Name implements ArchiveProvider
+func (ArchiveTodayProvider) Name() string { return "archive.today" }
+
+// Lookup implements ArchiveProvider. The newest capture wins; archive.today
+// doesn't report the captured status, so Status is "archive.today".
+func (ArchiveTodayProvider) Lookup(ctx context.Context, raw string) (ArchiveSnapshot, error) {
+	archiveTodayPause.Lock()
+	until := archiveTodayPause.until
+	archiveTodayPause.Unlock()
+	if time.Now().Before(until) {
+		return ArchiveSnapshot{}, errArchiveTodayRateLimited
+	}
+
+	cfg := currentConfig()
+	logger := logFor(ctx, "archive.today").With("url", raw)
+	ctx, done := withCheckTimeout(ctx, cfg.WaybackTimeout, logger)
+	defer done()
+
+	logger.Debug("checking timemap")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveTodayTimemap+raw, nil)
+	if err != nil {
+		return ArchiveSnapshot{}, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.Warn("request failed", "error", err)
+		return ArchiveSnapshot{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ArchiveSnapshot{Status: "not archived"}, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := retryAfter(resp.Header.Get("Retry-After"), archiveTodayCooldown, 10*archiveTodayCooldown)
+		archiveTodayPause.Lock()
+		archiveTodayPause.until = time.Now().Add(wait)
+		archiveTodayPause.Unlock()
+		logger.Warn("rate limited, pausing lookups", "for", wait)
+		return ArchiveSnapshot{}, errArchiveTodayRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return ArchiveSnapshot{}, fmt.Errorf("archive.today timemap returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		return ArchiveSnapshot{}, err
+	}
+	best := bestMemento(parseLinkHeader(strings.ReplaceAll(string(body), "\n", "")), "")
+	if best.URL == "" || best.Datetime.IsZero() {
+		logger.Info("no capture found")
+		return ArchiveSnapshot{Status: "not archived"}, nil
+	}
+	logger.Info("found capture", "archive_url", best.URL, "timestamp", best.Datetime.Format(time.RFC3339))
+	return ArchiveSnapshot{Found: true, URL: best.URL, Status: "archive.today", Timestamp: best.Datetime}, nil
+}
@@ -0,0 +1,26 @@
+package handler
+
+import "regexp"
+
+// Wayback capture URL flavors, selected with Config.ArchiveURLFlavor. The
+// modifier follows the timestamp: web.archive.org/web/20200101000000id_/...
+const (
+	ArchiveFlavorDefault  = ""    // Toolbar and rewritten links
+	ArchiveFlavorRaw      = "id_" // Original bytes as captured, no rewriting
+	ArchiveFlavorNoBanner = "if_" // Rewritten links, but no Wayback banner
+)
+
+// waybackCapturePattern splits a capture URL into prefix, timestamp, any
+// existing modifier ("id_", "if_", "im_", ...) and the archived URL
+var waybackCapturePattern = regexp.MustCompile(`^((?:https?:)?//(?:web|wayback)\.archive\.org/web/)(\d{1,14})(?:[a-z]{2}_)?(/.+)$`)
+
+// waybackFlavorURL rewrites a Wayback capture URL to use modifier in place
+// of whatever modifier it had (ArchiveFlavorDefault removes it). URLs that
+// aren't single-capture Wayback URLs are returned unchanged.
+func waybackFlavorURL(archiveURL, modifier string) string {
+	m := waybackCapturePattern.FindStringSubmatch(archiveURL)
+	if m == nil {
+		return archiveURL
+	}
+	return m[1] + m[2] + modifier + m[3]
+}
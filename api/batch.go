@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxBatchPages is the most pages one /api/scan/batch request may scan
+	maxBatchPages = 20
+	// batchPageWorkers is how many pages of a batch are scanned at once.
+	// Links within each page still use Config.Workers, and the per-host
+	// limiter is shared by all of them.
+	batchPageWorkers = 2
+)
+
+// ScanBatchHandler handles POST /api/scan/batch. The body is a JSON array of
+// page titles (at most maxBatchPages). The response is a JSON object
+// {"pages": {"<title>": <ScanResponse>, ...}} whose entries are streamed as
+// each page finishes, so a failed page carries its own error rather than
+// failing the batch.
+func ScanBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var titles []string
+	if err := json.NewDecoder(r.Body).Decode(&titles); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(titles) == 0 {
+		http.Error(w, "No pages provided", http.StatusBadRequest)
+		return
+	}
+
+	var pages, invalid []string
+	seen := make(map[string]bool)
+	for _, t := range titles {
+		page, err := validatePageTitle(t)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q (%v)", t, err))
+			continue
+		}
+		if !seen[page] {
+			seen[page] = true
+			pages = append(pages, page)
+		}
+	}
+	if len(invalid) > 0 {
+		http.Error(w, "Invalid page titles: "+strings.Join(invalid, ", "), http.StatusBadRequest)
+		return
+	}
+	if len(pages) > maxBatchPages {
+		http.Error(w, fmt.Sprintf("Too many pages: %d (limit %d)", len(pages), maxBatchPages), http.StatusBadRequest)
+		return
+	}
+
+	since, err := parseAddedSince(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oldid, err := parseOldID(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	opts := scanOptions{archiveOnly: archiveOnlyRequested(r), addedSince: since, oldid: oldid}
+	logFor(ctx, "scan").Info("starting batch", "pages", len(pages))
+
+	done := make(chan ScanResponse)
+	sem := make(chan struct{}, batchPageWorkers)
+	var wg sync.WaitGroup
+	for _, page := range pages {
+		wg.Add(1)
+		go func(page string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				done <- ScanResponse{Page: page, Results: []linkResult{}, Error: newErrorBody(ctx.Err())}
+				return
+			}
+			results, citationMap, err := scanPageWith(ctx, page, opts)
+			resp := ScanResponse{Page: page, AddedSince: since, Revision: scannedRevision(citationMap), Summary: summarizeResults(results, citationMap), Results: results}
+			resp.Title, resp.RedirectedFrom = scannedTitle(citationMap, page)
+			if resp.Results == nil {
+				resp.Results = []linkResult{}
+			}
+			if err != nil {
+				_, resp.Partial = asPartialScan(err)
+				resp.Error = newErrorBody(err)
+			}
+			done <- resp
+		}(page)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Each page is written as soon as it finishes; the client sees one JSON
+	// object once the last page is in
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	fmt.Fprint(w, `{"pages":{`)
+	first := true
+	for resp := range done {
+		key, _ := json.Marshal(resp.Page)
+		body, err := json.Marshal(resp)
+		if err != nil {
+			continue
+		}
+		if !first {
+			fmt.Fprint(w, ",")
+		}
+		first = false
+		fmt.Fprintf(w, "\n%s:%s", key, body)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	fmt.Fprint(w, "\n}}\n")
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CheckResponse is the JSON envelope for /api/check
+type CheckResponse struct {
+	Results []linkResult `json:"results"`
+	Error   *errorBody   `json:"error,omitempty"`
+}
+
+// CheckHandler handles POST /api/check. The body is a JSON array of absolute
+// http(s) URLs, which go through the same dedup, cap and check pipeline as
+// a page scan. With ?format=jsonl the results are streamed instead, see
+// streamCheckResults.
+func CheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var urls []string
+	if err := json.NewDecoder(r.Body).Decode(&urls); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(urls) == 0 {
+		http.Error(w, "No URLs provided", http.StatusBadRequest)
+		return
+	}
+
+	var invalid []string
+	for i, u := range urls {
+		urls[i] = strings.TrimSpace(u)
+		if !isAbsoluteHTTPURL(urls[i]) {
+			invalid = append(invalid, u)
+		}
+	}
+	if len(invalid) > 0 {
+		http.Error(w, fmt.Sprintf("Not absolute http(s) URLs: %s", strings.Join(invalid, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withScanID(r.Context())
+	cfg := currentConfig()
+	out, _ := prepareURLs(ctx, urls, nil, cfg)
+	opts := scanOptions{archiveOnly: archiveOnlyRequested(r)}
+	if strings.EqualFold(r.URL.Query().Get("format"), "jsonl") {
+		streamCheckResults(w, ctx, out, opts)
+		return
+	}
+	results, err := checkLinks(ctx, out, nil, opts)
+
+	resp := CheckResponse{Results: results}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		resp.Error = newErrorBody(err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// streamCheckResults writes each result as one line of newline-delimited
+// JSON as soon as its check finishes, in completion order, flushing after
+// every line so clients can consume a large batch incrementally. A scan
+// error (e.g. the time limit) ends the stream with an {"error": ...} line.
+// The checks run on ctx, so a client that disconnects cancels them.
+func streamCheckResults(w http.ResponseWriter, ctx context.Context, urls []string, opts scanOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	opts.onResult = func(checked, total int, lr linkResult) {
+		// Write errors mean the client is gone; ctx is cancelled with it
+		if enc.Encode(lr) == nil {
+			flusher.Flush()
+		}
+	}
+	if _, err := checkLinks(ctx, urls, nil, opts); err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		enc.Encode(map[string]*errorBody{"error": newErrorBody(err)})
+		flusher.Flush()
+	}
+}
+
+// isAbsoluteHTTPURL reports whether raw is an absolute http or https URL with a host
+func isAbsoluteHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
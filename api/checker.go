@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// prepareURLs sorts, collapses equivalent spellings and applies the
+// MaxLinks cap. The returned map holds the merged citation numbers of each
+// kept URL (empty when cm is nil).
+func prepareURLs(ctx context.Context, urls []string, cm *CitationMap, cfg Config) ([]string, map[string][]int) {
+	out := append([]string(nil), urls...)
+	sort.Strings(out)
+	out, citationNumbers := collapseEquivalentURLs(out, cm, cfg)
+	if len(out) > cfg.MaxLinks {
+		logFor(ctx, "scan").Info("limiting unique links", "limit", cfg.MaxLinks, "unique_urls", len(out))
+		out = out[:cfg.MaxLinks]
+	} else {
+		logFor(ctx, "scan").Info("processing unique links", "unique_urls", len(out))
+	}
+	return out, citationNumbers
+}
+
+// scanOptions adjust how a scan or batch check runs
+type scanOptions struct {
+	// archiveOnly skips the live check and only looks for archives, for
+	// finding archive gaps when reachability doesn't matter
+	archiveOnly bool
+	// addedSince, if set, restricts a page scan to links added after this
+	// revision ID or RFC3339 time (see parseAddedSince)
+	addedSince string
+	// oldid, if set, scans that revision of the page instead of the
+	// current one (see parseOldID)
+	oldid int64
+	// onResult, if non-nil, is called once per completed link in completion
+	// order; calls never overlap
+	onResult resultFunc
+	// decorate, if non-nil, adds what a page scan knows about a link
+	// (citation context, cited archive, ...) before it is reported. It runs
+	// on the worker, so it may make requests of its own.
+	decorate func(ctx context.Context, lr *linkResult)
+}
+
+// key identifies a scan of title with these options, for sharing and
+// caching results
+func (o scanOptions) key(title string) string {
+	return fmt.Sprintf("%s|%s|archive_only=%t|added_since=%s|oldid=%d", mediaWikiAPI, title, o.archiveOnly, o.addedSince, o.oldid)
+}
+
+// archiveOnlyRequested reports whether the request asks for an archive-only
+// scan (?archive_only=1)
+func archiveOnlyRequested(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("archive_only"))
+	return v
+}
+
+// resultFunc is told about each link as it completes, with how many of the
+// total have been checked so far
+type resultFunc func(checked, total int, lr linkResult)
+
+// partialScanError reports a scan that ran out of time (or was cancelled)
+// part way through. The links checked so far are still returned with it.
+type partialScanError struct {
+	checked, total int
+	err            error // The context error
+}
+
+func (e *partialScanError) Error() string {
+	if errors.Is(e.err, context.DeadlineExceeded) {
+		return fmt.Sprintf("scan timed out after %d of %d links", e.checked, e.total)
+	}
+	return fmt.Sprintf("scan cancelled after %d of %d links: %v", e.checked, e.total, e.err)
+}
+
+func (e *partialScanError) Unwrap() error { return e.err }
+
+// asPartialScan reports whether err is a partial scan, i.e. whether the
+// results returned with it are worth showing
+func asPartialScan(err error) (*partialScanError, bool) {
+	var pe *partialScanError
+	ok := errors.As(err, &pe)
+	return pe, ok
+}
+
+// checkLinks runs the live + archive pipeline over urls using cfg.Workers
+// concurrent workers. Results come back in Config.ResultOrder, where URL
+// order is the order of urls. If ctx ends early, the links completed so far
+// are returned (in the same order) with a *partialScanError.
+//
+// Ordering guarantees: links are handed to workers strictly in the order of
+// urls, so the i-th check starts no earlier than the (i-1)-th, and for a
+// given input the URL and citation orders of the results are always the
+// same. Only completion order, log interleaving and which link waits on a
+// host's rate limit depend on timing; with Config.Workers at 1 those are
+// fixed too.
+func checkLinks(ctx context.Context, urls []string, citationNumbers map[string][]int, opts scanOptions) ([]linkResult, error) {
+	cfg := currentConfig()
+	workers := cfg.Workers
+	if workers > len(urls) {
+		workers = len(urls)
+	}
+
+	agg := newResultAggregator(len(urls), cfg.ResultOrder)
+	jobs := make(chan int)
+	var progressMu sync.Mutex
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				lr := checkLink(ctx, urls[i], i, len(urls), opts.archiveOnly)
+				lr.CitationNumbers = citationNumbers[urls[i]]
+				if opts.decorate != nil {
+					opts.decorate(ctx, &lr)
+				}
+				if opts.onResult == nil {
+					agg.add(i, lr)
+					continue
+				}
+				// Count and report under one lock so progress never runs backwards
+				progressMu.Lock()
+				opts.onResult(agg.add(i, lr), len(urls), lr)
+				progressMu.Unlock()
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for i := range urls {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- i:
+			dispatched++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	results := agg.collect()
+	if err := ctx.Err(); err != nil && dispatched < len(urls) {
+		partial := results
+		logFor(ctx, "scan").Warn("context cancelled", "processed", len(partial), "total", len(urls), "error", err)
+		return partial, &partialScanError{checked: len(partial), total: len(urls), err: err}
+	}
+	return results, nil
+}
+
+// withCheckTimeout gives one check (live, Wayback, ...) its own deadline:
+// timeout, or whatever is left of the scan's budget if that is sooner.
+// The returned func must be called when the check is done; it logs when
+// the check was cut short by the scan budget rather than its own timeout.
+func withCheckTimeout(ctx context.Context, timeout time.Duration, logger *slog.Logger) (context.Context, context.CancelFunc) {
+	budgetBound := false
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		budgetBound = true
+		timeout = time.Until(deadline)
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	start := time.Now()
+	return checkCtx, func() {
+		if budgetBound && errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			logger.Warn("check cut short by the scan time limit", "after", time.Since(start).Round(time.Millisecond), "timeout", timeout)
+		}
+		cancel()
+	}
+}
+
+// checkLink runs the live and archive checks for a single URL.
+// i and total are only used for progress logging. With archiveOnly the live
+// check is skipped and marked as such.
+func checkLink(ctx context.Context, u string, i, total int, archiveOnly bool) linkResult {
+	logger := logFor(ctx, "scan").With("url", u, "index", i+1, "total", total)
+	logger.Info("checking link")
+	lr := linkResult{URL: u}
+
+	// Skip live/archive checks for URLs that are already archives, or with
+	// Config.CheckArchiveURLs only check that the archive itself still works
+	if isArchiveURL(u) {
+		lr.LiveCode = 0
+		lr.LiveStatus = "archive URL (skipped)"
+		lr.LiveCategory = liveCategorySkipped
+		lr.Archived = true
+		lr.ArchiveURL = u
+		lr.ArchiveStatus = "is archive"
+		if currentConfig().CheckArchiveURLs {
+			live := checkLive(ctx, asciiURL(u))
+			lr.LiveCode, lr.LiveStatus, lr.LiveCategory = live.Code, live.Status, live.Category
+			lr.RedirectChain = live.RedirectChain
+			// A blocked or timed-out archive isn't known to be dead
+			alive := computeVerdict(live.Code, live.Status, false) != verdictDead
+			lr.ArchiveLive = &alive
+			if !alive {
+				lr.Archived = false
+				lr.ArchiveStatus = "archive is dead"
+			}
+			// Dead archive -> dead; working one -> alive
+			lr.Verdict = computeVerdict(lr.LiveCode, lr.LiveStatus, alive)
+			lr.Complete = linkComplete(ctx, lr, currentConfig())
+			linksCheckedTotal.WithLabelValues(lr.Verdict).Inc()
+			logger.Info("checked archive URL", "archive_live", alive, "status", live.Status)
+			return lr
+		}
+		lr.Verdict = computeVerdict(lr.LiveCode, lr.LiveStatus, lr.Archived)
+		lr.Complete = linkComplete(ctx, lr, currentConfig())
+		linksCheckedTotal.WithLabelValues(lr.Verdict).Inc()
+		logger.Info("detected as archive URL, skipping checks")
+		return lr
+	}
+
+	// Requests use the punycode form of international hosts; lr.URL keeps
+	// the form the article cites
+	reqURL := asciiURL(u)
+
+	if archiveOnly {
+		lr.LiveSkipped = true
+		lr.LiveStatus = "skipped (archive-only)"
+		lr.LiveCategory = liveCategorySkipped
+	} else {
+		live := checkLive(ctx, reqURL)
+		lr.LiveCode = live.Code
+		lr.LiveStatus = live.Status
+		lr.LiveCategory = live.Category
+		lr.RedirectChain = live.RedirectChain
+		lr.ContentType, lr.ContentLength = live.ContentType, live.ContentLength
+		if len(live.RedirectChain) > 0 {
+			lr.RedirectedOffDomain = isOffDomain(reqURL, live.FinalURL)
+			lr.ResolvedURL = resolvedURL(reqURL, live.FinalURL, currentConfig().ShortenerHosts)
+		}
+		if t := live.TLS; t != nil {
+			lr.TLSIssuer = t.Issuer
+			if !t.Expiry.IsZero() {
+				expiry := t.Expiry
+				lr.CertExpiry = &expiry
+			}
+			now := time.Now()
+			lr.TLSExpired = t.expired(now)
+			lr.TLSExpiringSoon = t.expiringSoon(now)
+		}
+		logger.Info("live check", "status_code", live.Code, "status", live.Status)
+
+		if cfg := currentConfig(); live.Code >= 200 && live.Code < 300 {
+			target := reqURL
+			if live.FinalURL != "" {
+				target = live.FinalURL
+			}
+			if cfg.DetectParked {
+				lr.Parked = checkParked(ctx, target)
+			}
+			// Documents, images and the like have no <meta> refresh to find
+			if cfg.InspectBody && isHTMLType(live.ContentType) {
+				if ref := checkRefresh(ctx, target); ref != "" {
+					lr.RefreshTarget = ref
+					lr.LiveStatus += " (refreshes to " + ref + ")"
+					if isOffDomain(reqURL, ref) {
+						lr.RedirectedOffDomain = true
+					}
+				}
+			}
+		}
+	}
+
+	archiveURL := reqURL
+	if target := archiveTarget(lr, currentConfig()); target != lr.URL {
+		archiveURL = target
+		logger.Info("looking up shortener destination in archives", "resolved_url", target)
+	}
+	wb := checkArchives(ctx, archiveURL)
+	lr.Archived = wb.Archived
+	lr.ArchiveURL = wb.URL
+	lr.ArchiveStatus = wb.Status
+	lr.ArchiveSnapshotStatus = wb.SnapshotStatus
+	if wb.Archived {
+		lr.ArchiveSource = wb.Source
+	}
+	if !wb.Timestamp.IsZero() {
+		lr.ArchiveTimestamp = wb.Timestamp.Format(time.RFC3339)
+		lr.ArchiveAge = archiveAge(wb.Timestamp, time.Now())
+		if stale := currentConfig().ArchiveStaleAfter; stale > 0 && time.Since(wb.Timestamp) > stale {
+			lr.ArchiveStale = true
+		}
+	}
+	logger.Info("wayback check", "archived", wb.Archived, "status", wb.Status, "source", wb.Source)
+
+	lr.Verdict = computeVerdict(lr.LiveCode, lr.LiveStatus, lr.Archived)
+	linksCheckedTotal.WithLabelValues(lr.Verdict).Inc()
+	return lr
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeVerdict(t *testing.T) {
+	useConfig(t, testConfig())
+	tests := []struct {
+		code     int
+		status   string
+		archived bool
+		want     string
+	}{
+		{200, "OK", true, verdictAlive},
+		{200, "OK", false, verdictAliveUnarchived},
+		{301, "301 Moved Permanently", false, verdictAliveUnarchived},
+		{403, "403 Forbidden", false, verdictBlocked},
+		{429, "429 Rate Limited", true, verdictBlocked},
+		{404, "404 Not Found", false, verdictDead},
+		{404, "404 Not Found", true, verdictDeadArchived},
+		{503, "503 Service Unavailable", false, verdictDead},
+		{0, "DNS lookup failed", false, verdictDead},
+		{0, "connection refused", true, verdictDeadArchived},
+		{0, "timeout", false, verdictUnknown},
+		{0, "TLS/certificate error", false, verdictUnknown},
+		{301, statusTooManyRedirects, false, verdictUnknown},
+	}
+	for _, tt := range tests {
+		if got := computeVerdict(tt.code, tt.status, tt.archived); got != tt.want {
+			t.Errorf("computeVerdict(%d, %q, %v) = %q; want %q", tt.code, tt.status, tt.archived, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		snapshot     string // Availability API answer
+		wantVerdict  string
+		wantArchived bool
+	}{
+		{"alive, archived", "/ok", waybackResponse("200"), verdictAlive, true},
+		{"alive, unarchived", "/ok", `{"archived_snapshots": {}}`, verdictAliveUnarchived, false},
+		{"dead, archived", "/gone", waybackResponse("200"), verdictDeadArchived, true},
+		{"dead, unarchived", "/gone", `{"archived_snapshots": {}}`, verdictDead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Host == "archive.org":
+					w.Write([]byte(tt.snapshot))
+				case r.URL.Path == "/ok":
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			lr := checkLink(context.Background(), srv.URL+tt.path, 0, 1, false)
+			if lr.Verdict != tt.wantVerdict || lr.Archived != tt.wantArchived {
+				t.Errorf("checkLink = %q, archived %v; want %q, %v", lr.Verdict, lr.Archived, tt.wantVerdict, tt.wantArchived)
+			}
+			if tt.wantArchived && !strings.HasPrefix(lr.ArchiveURL, "http://web.archive.org/web/") {
+				t.Errorf("archive URL = %q", lr.ArchiveURL)
+			}
+		})
+	}
+}
+
+func TestCheckLinksKeepsURLOrder(t *testing.T) {
+	urls := []string{"https://a.example.com/", "https://b.example.com/", "https://c.example.com/", "https://d.example.com/"}
+	delays := map[string]time.Duration{
+		urls[0]: 60 * time.Millisecond,
+		urls[1]: 40 * time.Millisecond,
+		urls[2]: 20 * time.Millisecond,
+	}
+	cfg := testConfig()
+	cfg.Workers = len(urls)
+	stubUpstream(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Earlier links answer later, so completion order is reversed
+		time.Sleep(delays[r.URL.Query().Get("url")])
+		w.Write([]byte(`{"archived_snapshots": {}}`))
+	}))
+
+	for run := 0; run < 3; run++ {
+		results, err := checkLinks(context.Background(), urls, nil, scanOptions{archiveOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, lr := range results {
+			got = append(got, lr.URL)
+		}
+		if !reflect.DeepEqual(got, urls) {
+			t.Errorf("run %d: results in order %q; want %q", run+1, got, urls)
+		}
+	}
+}
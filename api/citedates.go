@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateOrder says how to read an all-numeric date like 03/04/2019
+type dateOrder int
+
+const (
+	dateOrderUnknown dateOrder = iota // Ambiguous numeric dates are rejected
+	dateOrderDMY                      // 03/04/2019 is 3 April
+	dateOrderMDY                      // 03/04/2019 is 4 March
+)
+
+// wikiDateOrders is the usual numeric date order per wiki language. Wikis
+// not listed get dateOrderUnknown, so ambiguous dates aren't guessed.
+var wikiDateOrders = map[string]dateOrder{
+	"en": dateOrderMDY,
+	"de": dateOrderDMY,
+	"es": dateOrderDMY,
+	"fr": dateOrderDMY,
+	"it": dateOrderDMY,
+	"nl": dateOrderDMY,
+	"pl": dateOrderDMY,
+	"pt": dateOrderDMY,
+	"ru": dateOrderDMY,
+}
+
+// useDatesPattern matches the {{Use dmy dates}} / {{Use mdy dates}}
+// templates English Wikipedia articles declare their date style with
+var useDatesPattern = regexp.MustCompile(`(?i)\{\{\s*use\s+(dmy|mdy)\s+dates\b`)
+
+// wikiLanguage returns the language subdomain of the wiki we scan, "en"
+// for en.wikipedia.org
+func wikiLanguage() string {
+	u, err := url.Parse(mediaWikiAPI)
+	if err != nil {
+		return ""
+	}
+	lang, _, _ := strings.Cut(u.Hostname(), ".")
+	return lang
+}
+
+// articleDateOrder returns the date order an article declares with {{Use
+// dmy dates}} or {{Use mdy dates}}, falling back to the wiki's usual one
+func articleDateOrder(wikitext string) dateOrder {
+	if m := useDatesPattern.FindStringSubmatch(wikitext); m != nil {
+		if strings.EqualFold(m[1], "dmy") {
+			return dateOrderDMY
+		}
+		return dateOrderMDY
+	}
+	return wikiDateOrders[wikiLanguage()]
+}
+
+// citationDateLayouts are the unambiguous forms dates take in citation
+// templates: ISO, day-month-year and month-day-year with full or short
+// month names
+var citationDateLayouts = []string{
+	"2006-01-02",
+	"2 January 2006",
+	"2 Jan 2006",
+	"January 2, 2006",
+	"January 2 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+}
+
+// numericDatePattern matches 03/04/2019, 3.4.2019 and 03-04-2019
+var numericDatePattern = regexp.MustCompile(`^(\d{1,2})[/.\-](\d{1,2})[/.\-](\d{4})$`)
+
+// parseCitationDate reads a template date such as |archive-date=. Only
+// all-numeric dates depend on order: a day over 12 settles them, otherwise
+// order decides, and with dateOrderUnknown they are an error rather than a
+// guess. Partial dates ("March 2019") are errors too.
+func parseCitationDate(s string, order dateOrder) (time.Time, error) {
+	s = strings.Join(strings.Fields(strings.ReplaceAll(s, "&nbsp;", " ")), " ")
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	for _, layout := range citationDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	m := numericDatePattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+	year, _ := strconv.Atoi(m[3])
+	var day, month int
+	switch {
+	case a > 12 && b <= 12:
+		day, month = a, b
+	case b > 12 && a <= 12:
+		day, month = b, a
+	case order == dateOrderDMY:
+		day, month = a, b
+	case order == dateOrderMDY:
+		day, month = b, a
+	default:
+		return time.Time{}, fmt.Errorf("ambiguous date %q: day and month order unknown", s)
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalises 31/02 into March; a real date survives unchanged
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// citationTime parses a template date for CitedSource, nil when it is
+// missing or can't be read unambiguously
+func citationTime(s string, order dateOrder) *time.Time {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	t, err := parseCitationDate(s, order)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCitationDate(t *testing.T) {
+	march15 := time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s       string
+		order   dateOrder
+		want    time.Time
+		wantErr bool
+	}{
+		{"15 March 2019", dateOrderUnknown, march15, false},
+		{"March 15, 2019", dateOrderUnknown, march15, false},
+		{"2019-03-15", dateOrderUnknown, march15, false},
+		{"15 Mar 2019", dateOrderUnknown, march15, false},
+		{"Mar 15, 2019", dateOrderUnknown, march15, false},
+		{"March 15 2019", dateOrderUnknown, march15, false},
+		{"15&nbsp;March  2019", dateOrderUnknown, march15, false},
+		{"15/03/2019", dateOrderUnknown, march15, false}, // Day over 12 settles the order
+		{"03/15/2019", dateOrderDMY, march15, false},
+		{"03/04/2019", dateOrderDMY, time.Date(2019, 4, 3, 0, 0, 0, 0, time.UTC), false},
+		{"03/04/2019", dateOrderMDY, time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC), false},
+		{"3.4.2019", dateOrderDMY, time.Date(2019, 4, 3, 0, 0, 0, 0, time.UTC), false},
+		{"03/04/2019", dateOrderUnknown, time.Time{}, true},
+		{"31/02/2019", dateOrderDMY, time.Time{}, true},
+		{"2019-02-30", dateOrderUnknown, time.Time{}, true},
+		{"March 2019", dateOrderMDY, time.Time{}, true},
+		{"", dateOrderMDY, time.Time{}, true},
+		{"yesterday", dateOrderMDY, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCitationDate(tt.s, tt.order)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseCitationDate(%q, %d) = %v, %v; want %v, error %v", tt.s, tt.order, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestArticleDateOrder(t *testing.T) {
+	tests := []struct {
+		wikitext string
+		want     dateOrder
+	}{
+		{"{{Use dmy dates|date=March 2019}}\nText", dateOrderDMY},
+		{"{{use mdy dates}}", dateOrderMDY},
+		{"No template", dateOrderMDY}, // en.wikipedia.org's usual order
+	}
+	for _, tt := range tests {
+		if got := articleDateOrder(tt.wikitext); got != tt.want {
+			t.Errorf("articleDateOrder(%q) = %d; want %d", tt.wikitext, got, tt.want)
+		}
+	}
+}
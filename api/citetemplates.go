@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TemplateUsage is one citation template on a page and how often the
+// wikitext calls it directly. Templates only reached through other
+// templates have Count 0.
+type TemplateUsage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// citationTemplateNames are templates that cite or annotate sources besides
+// the "Cite ..." family
+var citationTemplateNames = map[string]bool{
+	"Citation":   true,
+	"Sfn":        true,
+	"Harvnb":     true,
+	"Webarchive": true,
+	"Dead link":  true,
+}
+
+// templateCallPattern matches the name in a template call: {{Cite web |...
+var templateCallPattern = regexp.MustCompile(`\{\{\s*([^{}|\n]+?)\s*(?:\||\}\})`)
+
+// citationTemplateUsage picks the citation templates out of the page's
+// transcluded templates (as listed by prop=templates, e.g. "Template:Cite
+// web") and counts their direct calls in wikitext. The most used come first.
+func citationTemplateUsage(transcluded []string, wikitext string) []TemplateUsage {
+	counts := make(map[string]int)
+	for _, m := range templateCallPattern.FindAllStringSubmatch(wikitext, -1) {
+		counts[normalizeTemplateName(m[1])]++
+	}
+
+	var usage []TemplateUsage
+	seen := make(map[string]bool)
+	for _, t := range transcluded {
+		name := normalizeTemplateName(t)
+		if seen[name] || !isCitationTemplate(name) {
+			continue
+		}
+		seen[name] = true
+		usage = append(usage, TemplateUsage{Name: name, Count: counts[name]})
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Count > usage[j].Count })
+	return usage
+}
+
+// normalizeTemplateName maps the spellings MediaWiki treats as one template
+// ("template:cite_web", "Cite  web") to a single form ("Cite web")
+func normalizeTemplateName(name string) string {
+	name = strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " ")
+	if ns, rest, ok := strings.Cut(name, ":"); ok && strings.EqualFold(strings.TrimSpace(ns), "template") {
+		name = strings.TrimSpace(rest)
+	}
+	if r, size := utf8.DecodeRuneInString(name); r != utf8.RuneError {
+		name = string(unicode.ToUpper(r)) + name[size:]
+	}
+	return name
+}
+
+func isCitationTemplate(name string) bool {
+	return strings.HasPrefix(name, "Cite ") || citationTemplateNames[name]
+}
+
+// CitedSource is a source URL together with the archive the citation
+// already pairs it with, from one template call such as
+// {{cite web |url=... |archive-url=... |archive-date=...}}
+type CitedSource struct {
+	URL         string `json:"url"`
+	ArchiveURL  string `json:"archive_url,omitempty"`
+	ArchiveDate string `json:"archive_date,omitempty"` // As written
+	AccessDate  string `json:"access_date,omitempty"`  // As written
+	URLStatus   string `json:"url_status,omitempty"`   // |url-status= as written: live, dead, unfit, usurped, ...
+
+	// ArchivedOn and AccessedOn are ArchiveDate and AccessDate parsed (see
+	// parseCitationDate); nil when missing or not readable unambiguously
+	ArchivedOn *time.Time `json:"archived_on,omitempty"`
+	AccessedOn *time.Time `json:"accessed_on,omitempty"`
+}
+
+// citedSources reads the url/archive-url pairs of the template calls in
+// ref content. A {{webarchive}} right after a citation supplies the
+// archive of that citation's url when it has none of its own. Dates are
+// read with the article's date order (see articleDateOrder).
+func citedSources(content string, order dateOrder) []CitedSource {
+	var sources []CitedSource
+	ignored := currentConfig().IgnoredHosts
+	for _, call := range templateCalls(stripInactiveMarkup(content)) {
+		name, params := templateParams(call)
+		if normalizeTemplateName(name) == "Webarchive" {
+			if n := len(sources); n > 0 && sources[n-1].ArchiveURL == "" {
+				sources[n-1].ArchiveURL = paramURL(params, "url")
+				sources[n-1].ArchiveDate = params["date"]
+				sources[n-1].ArchivedOn = citationTime(params["date"], order)
+			}
+			continue
+		}
+		u := paramURL(params, "url")
+		if u == "" || isIgnoredURL(u, ignored) {
+			continue
+		}
+		src := CitedSource{
+			URL:         u,
+			ArchiveURL:  paramURL(params, "archive-url", "archiveurl"),
+			ArchiveDate: firstParam(params, "archive-date", "archivedate"),
+			AccessDate:  firstParam(params, "access-date", "accessdate"),
+			URLStatus:   firstParam(params, "url-status", "deadurl"),
+		}
+		src.ArchivedOn = citationTime(src.ArchiveDate, order)
+		src.AccessedOn = citationTime(src.AccessDate, order)
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// templateCalls returns the outermost {{...}} calls in wikitext, nested
+// templates included in their text
+func templateCalls(wikitext string) []string {
+	var calls []string
+	depth, start := 0, 0
+	for i := 0; i+1 < len(wikitext); i++ {
+		switch wikitext[i : i+2] {
+		case "{{":
+			if depth == 0 {
+				start = i
+			}
+			depth++
+			i++
+		case "}}":
+			if depth == 0 {
+				continue
+			}
+			depth--
+			i++
+			if depth == 0 {
+				calls = append(calls, wikitext[start:i+1])
+			}
+		}
+	}
+	return calls
+}
+
+// templateParams splits a template call into its name and named
+// parameters (lower-cased keys, trimmed values). Pipes inside nested
+// templates and [[links|labels]] don't split; positional parameters are
+// dropped.
+func templateParams(call string) (name string, params map[string]string) {
+	body := strings.TrimSuffix(strings.TrimPrefix(call, "{{"), "}}")
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(body); i++ {
+		switch {
+		case strings.HasPrefix(body[i:], "{{"), strings.HasPrefix(body[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(body[i:], "}}"), strings.HasPrefix(body[i:], "]]"):
+			depth = max(depth-1, 0)
+			i++
+		case body[i] == '|' && depth == 0:
+			parts = append(parts, body[last:i])
+			last = i + 1
+		}
+	}
+	parts = append(parts, body[last:])
+
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return strings.TrimSpace(parts[0]), params
+}
+
+// firstParam returns the first non-empty value among keys (alternative
+// spellings of one parameter)
+func firstParam(params map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := params[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// paramURL is firstParam cleaned up the way extractURLsFromContent cleans
+// URLs, so both agree on the spelling; anything but http(s) is dropped
+func paramURL(params map[string]string, keys ...string) string {
+	u := cleanURL(withScheme(firstParam(params, keys...)))
+	if !strings.HasPrefix(u, "http") {
+		return ""
+	}
+	return u
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCitedSources(t *testing.T) {
+	useConfig(t, testConfig())
+	tests := []struct {
+		name    string
+		content string
+		want    []CitedSource
+	}{
+		{
+			"url and archive-url",
+			`{{cite web |url=http://www.blink182.com/history |archive-url=https://web.archive.org/web/20190315/http://www.blink182.com/history |archive-date=2019-03-15 |url-status=dead}}`,
+			[]CitedSource{{URL: "http://www.blink182.com/history", ArchiveURL: "https://web.archive.org/web/20190315/http://www.blink182.com/history", ArchiveDate: "2019-03-15", URLStatus: "dead"}},
+		},
+		{
+			"old parameter spellings",
+			`{{Cite news|archiveurl=https://archive.ph/AbC|URL=https://example.com/a|archivedate=15 March 2019|deadurl=yes}}`,
+			[]CitedSource{{URL: "https://example.com/a", ArchiveURL: "https://archive.ph/AbC", ArchiveDate: "15 March 2019", URLStatus: "yes"}},
+		},
+		{
+			"no archive",
+			`{{cite web |url=https://example.com/a |title=[[Foo|Bar]] |access-date=March 15, 2019}}`,
+			[]CitedSource{{URL: "https://example.com/a", AccessDate: "March 15, 2019"}},
+		},
+		{
+			"webarchive after citation",
+			`{{cite web |url=https://example.com/a}} {{webarchive |url=https://web.archive.org/web/2020/https://example.com/a |date=2020-01-01}}`,
+			[]CitedSource{{URL: "https://example.com/a", ArchiveURL: "https://web.archive.org/web/2020/https://example.com/a", ArchiveDate: "2020-01-01"}},
+		},
+		{
+			"webarchive doesn't replace an archive",
+			`{{cite web |url=https://example.com/a |archive-url=https://archive.ph/AbC}} {{webarchive |url=https://web.archive.org/web/2020/https://example.com/a}}`,
+			[]CitedSource{{URL: "https://example.com/a", ArchiveURL: "https://archive.ph/AbC"}},
+		},
+		{
+			"two citations keep their own archives",
+			`{{cite web |url=https://example.com/a |archive-url=https://archive.ph/A}}; {{cite web |url=https://example.com/b}}`,
+			[]CitedSource{{URL: "https://example.com/a", ArchiveURL: "https://archive.ph/A"}, {URL: "https://example.com/b"}},
+		},
+		{
+			"nested template in another parameter",
+			`{{cite web |url=https://example.com/a |title={{lang|fr|Titre|x}} |archive-url=https://archive.ph/A}}`,
+			[]CitedSource{{URL: "https://example.com/a", ArchiveURL: "https://archive.ph/A"}},
+		},
+		{"commented out", `<!-- {{cite web |url=https://example.com/a}} -->`, nil},
+		{"ignored host", `{{cite web |url=https://en.wikipedia.org/wiki/Foo}}`, nil},
+		{"no url", `{{cite book |title=Foo |isbn=123}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := citedSources(tt.content, dateOrderUnknown)
+			if len(got) != len(tt.want) {
+				t.Fatalf("citedSources = %+v; want %+v", got, tt.want)
+			}
+			for i := range got {
+				g := got[i]
+				g.ArchivedOn, g.AccessedOn = nil, nil
+				if g != tt.want[i] {
+					t.Errorf("source %d = %+v; want %+v", i, g, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCitedSourcesDates(t *testing.T) {
+	useConfig(t, testConfig())
+	got := citedSources(`{{cite web |url=https://example.com/a |archive-url=https://archive.ph/A |archive-date=15 March 2019 |access-date=03/04/2019}}`, dateOrderDMY)
+	if len(got) != 1 {
+		t.Fatalf("got %d sources; want 1", len(got))
+	}
+	if want := time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC); got[0].ArchivedOn == nil || !got[0].ArchivedOn.Equal(want) {
+		t.Errorf("ArchivedOn = %v; want %v", got[0].ArchivedOn, want)
+	}
+	if want := time.Date(2019, 4, 3, 0, 0, 0, 0, time.UTC); got[0].AccessedOn == nil || !got[0].AccessedOn.Equal(want) {
+		t.Errorf("AccessedOn = %v; want %v", got[0].AccessedOn, want)
+	}
+}
+
+func TestCitedArchive(t *testing.T) {
+	useConfig(t, testConfig())
+	cm := ParseCitations(`<ref>{{cite web |url=https://example.com/a |archive-url=https://archive.ph/A}}</ref>` +
+		`<ref>{{cite web |url=https://example.com/a}}</ref><ref>[https://example.com/b B]</ref>`)
+	tests := []struct {
+		url     string
+		numbers []int
+		want    string
+	}{
+		{"https://example.com/a", []int{1, 2}, "https://archive.ph/A"},
+		{"https://example.com/a", []int{2}, ""},
+		{"https://example.com/b", []int{3}, ""},
+	}
+	for _, tt := range tests {
+		if got := cm.CitedArchive(tt.url, tt.numbers); got != tt.want {
+			t.Errorf("CitedArchive(%q, %v) = %q; want %q", tt.url, tt.numbers, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// statusTooManyRedirects is the live status of a check that hit
+// Config.MaxRedirects, which usually means a redirect loop
+const statusTooManyRedirects = "too many redirects"
+
+// httpDoer is the one method outbound code needs from an HTTP client. Every
+// check goes through httpClient, so a stub can stand in for the network.
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// httpClient is shared by all outbound requests so connections are pooled
+// and reused across a scan. Timeouts come from per-request contexts.
+// SetConfig replaces it with a fresh *http.Client; assign a stub after that
+// to take the network out of the picture.
+var httpClient httpDoer = newHTTPClient(DefaultConfig())
+
+func newHTTPClient(c Config) *http.Client {
+	pc := proxyConfig(c)
+	dialer := &net.Dialer{
+		Timeout:   c.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxyFunc(pc),
+		DialContext:           guardedDial(dialer, proxyAddrs(pc), c.AllowPrivateAddresses),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   c.ConnectTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	// Negative MaxRedirects follows none: the first 3xx is the answer
+	limit := max(c.MaxRedirects, 0)
+	return &http.Client{
+		Transport: userAgentTransport{base: hostHeaderTransport{base: transport, headers: c.HostHeaders}, userAgent: c.UserAgent},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			t, traced := req.Context().Value(redirectTraceKey{}).(*redirectTrace)
+			if len(via) > limit {
+				// Report the last response, flagged so it isn't taken as the
+				// answer; finish adds it to the chain
+				if traced && limit > 0 {
+					t.capped = true
+				}
+				return http.ErrUseLastResponse
+			}
+			if traced && req.Response != nil {
+				t.hops = append(t.hops, fmt.Sprintf("%d %s", req.Response.StatusCode, via[len(via)-1].URL))
+			}
+			if destinationGuarded(req.Context()) {
+				return checkDestination(req.Context(), req.URL, currentConfig())
+			}
+			return nil
+		},
+	}
+}
+
+// errResponseTimeout cancels a live check whose server accepted the
+// connection and request but didn't answer within Config.ResponseTimeout
+var errResponseTimeout = errors.New("response timeout")
+
+// withResponseTimeout returns a context that gives up on a request when
+// its response headers take longer than d to arrive after the request was
+// written, restarting the clock for each redirect hop. This is the
+// Transport's ResponseHeaderTimeout, but per request: the shared transport
+// also carries Save Page Now calls, which legitimately take longer. After
+// a cancellation, context.Cause of the returned context is
+// errResponseTimeout.
+func withResponseTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	var mu sync.Mutex
+	var timer *time.Timer
+	stop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(d, func() { cancel(errResponseTimeout) })
+		},
+		GotFirstResponseByte: stop,
+	}
+	return httptrace.WithClientTrace(ctx, trace), func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// responseTimeoutCause replaces the bare "context canceled" of a request
+// that withResponseTimeout gave up on with errResponseTimeout
+func responseTimeoutCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errResponseTimeout) {
+		return cause
+	}
+	return err
+}
+
+// userAgentTransport sends the configured User-Agent on requests that
+// don't set their own, so nothing goes out as Go-http-client
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && t.userAgent != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// proxyConfig resolves the proxies from the config, falling back to the
+// standard environment variables. ALL_PROXY applies to both schemes when
+// the scheme-specific variable is unset.
+func proxyConfig(c Config) *httpproxy.Config {
+	pc := httpproxy.FromEnvironment()
+
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if pc.HTTPProxy == "" {
+		pc.HTTPProxy = all
+	}
+	if pc.HTTPSProxy == "" {
+		pc.HTTPSProxy = all
+	}
+
+	if c.Proxy != "" {
+		pc.HTTPProxy = c.Proxy
+		pc.HTTPSProxy = c.Proxy
+	}
+	if c.NoProxy != "" {
+		pc.NoProxy = c.NoProxy
+	}
+	return pc
+}
+
+// proxyFunc picks the proxy for each request
+func proxyFunc(pc *httpproxy.Config) func(*http.Request) (*url.URL, error) {
+	fn := pc.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}
+}
+
+// proxyAddrs returns the host:port the transport dials for each configured
+// proxy
+func proxyAddrs(pc *httpproxy.Config) map[string]bool {
+	addrs := make(map[string]bool)
+	for _, raw := range []string{pc.HTTPProxy, pc.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			switch u.Scheme {
+			case "https":
+				port = "443"
+			case "socks5", "socks5h":
+				port = "1080"
+			default:
+				port = "80"
+			}
+		}
+		addrs[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+	return addrs
+}
+
+// errResponseTooLarge is returned when a response body exceeds
+// Config.MaxResponseBytes
+var errResponseTooLarge = errors.New("response too large")
+
+// readBody reads at most limit bytes of r, failing with errResponseTooLarge
+// instead of buffering an oversized body
+func readBody(r io.Reader, limit int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%w (over %d bytes)", errResponseTooLarge, limit)
+	}
+	return b, nil
+}
+
+type redirectTraceKey struct{}
+
+// redirectTrace collects the hops a single request follows
+type redirectTrace struct {
+	hops   []string
+	capped bool // Stopped at Config.MaxRedirects with another redirect pending
+}
+
+// withRedirectTrace returns a context that makes the shared client record
+// every redirect hop of requests made with it
+func withRedirectTrace(ctx context.Context) (context.Context, *redirectTrace) {
+	t := &redirectTrace{}
+	return context.WithValue(ctx, redirectTraceKey{}, t), t
+}
+
+// finish returns the full chain including the final response, plus the final
+// URL. The chain is nil when no redirect was followed.
+func (t *redirectTrace) finish(resp *http.Response) ([]string, string) {
+	final := resp.Request.URL.String()
+	if len(t.hops) == 0 {
+		return nil, final
+	}
+	chain := append(t.hops, fmt.Sprintf("%d %s", resp.StatusCode, final))
+	return chain, final
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		body    string
+		limit   int64
+		wantErr error
+	}{
+		{"", 10, nil},
+		{"0123456789", 10, nil},
+		{"0123456789a", 10, errResponseTooLarge},
+		{strings.Repeat("x", 1<<20), 1024, errResponseTooLarge},
+	}
+	for _, tt := range tests {
+		b, err := readBody(strings.NewReader(tt.body), tt.limit)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("readBody(%d bytes, %d) error = %v; want %v", len(tt.body), tt.limit, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(b) != tt.body {
+			t.Errorf("readBody(%d bytes, %d) = %d bytes", len(tt.body), tt.limit, len(b))
+		}
+		if err != nil && b != nil {
+			t.Errorf("readBody(%d bytes, %d) returned %d bytes with an error", len(tt.body), tt.limit, len(b))
+		}
+	}
+}
+
+func TestLookupNearResponseTooLarge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxResponseBytes = 64
+	stubUpstream(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(waybackResponse("200")))
+	}))
+	snap, err := WaybackProvider{}.LookupNear(context.Background(), "http://example.com/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Found || snap.Status != "response too large" {
+		t.Errorf("LookupNear = found %v, status %q; want not found, \"response too large\"", snap.Found, snap.Status)
+	}
+}
+
+func TestCheckLiveRedirectLimit(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if r.URL.Path == "/twice" && n > 2 {
+			return
+		}
+		// Redirects forever, to a new URL each time
+		http.Redirect(w, r, fmt.Sprintf("%s?n=%d", r.URL.Path, n), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		maxRedirects int
+		wantCode     int
+		wantStatus   string
+		wantCategory string
+		wantChain    int
+	}{
+		{"loop hits the cap", "/loop", 3, 302, statusTooManyRedirects, liveCategoryTooManyRedirects, 4},
+		{"loop with default cap", "/loop", 0, 302, statusTooManyRedirects, liveCategoryTooManyRedirects, 11},
+		{"within the cap", "/twice", 3, 200, "OK", liveCategorySuccess, 3},
+		{"redirects not followed", "/loop", -1, 302, "302 Found", liveCategoryRedirect, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			cfg := testConfig()
+			cfg.MaxRedirects = tt.maxRedirects
+			useConfig(t, cfg)
+
+			got := checkLive(context.Background(), srv.URL+tt.path)
+			if got.Code != tt.wantCode || got.Status != tt.wantStatus || got.Category != tt.wantCategory {
+				t.Errorf("checkLive = %d %q (%s); want %d %q (%s)", got.Code, got.Status, got.Category, tt.wantCode, tt.wantStatus, tt.wantCategory)
+			}
+			if len(got.RedirectChain) != tt.wantChain {
+				t.Errorf("redirect chain = %q; want %d entries", got.RedirectChain, tt.wantChain)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies turns a comma-separated list of proxy addresses and
+// CIDR ranges ("10.0.0.0/8,192.0.2.7") into Config.TrustedProxies
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy range %q", s)
+			}
+			proxies = append(proxies, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q", s)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// trustedProxy reports whether addr is one of cfg.TrustedProxies, or any
+// address with cfg.TrustForwardedFor
+func trustedProxy(addr netip.Addr, cfg Config) bool {
+	if cfg.TrustForwardedFor {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, p := range cfg.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP identifies the caller for rate limiting and logs. Forwarding
+// headers only count when the connection comes from a trusted proxy;
+// otherwise anyone could pick their own address. X-Forwarded-For is read
+// from the right, skipping trusted proxies, so the first untrusted hop is
+// the client and whatever it claimed further left is ignored. Without
+// X-Forwarded-For the proxy's X-Real-IP is used.
+func clientIP(r *http.Request, cfg Config) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = host
+	}
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !trustedProxy(addr, cfg) {
+		return peer
+	}
+
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		hops := strings.Split(strings.Join(fwd, ","), ",")
+		client := addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// Garbage from further out; the last good hop is all we know
+				break
+			}
+			client = hop.Unmap()
+			if !trustedProxy(client, cfg) {
+				break
+			}
+		}
+		return client.String()
+	}
+	if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return real.Unmap().String()
+	}
+	return peer
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Concurrent identical scans share one execution. The shared scan runs on a
+// context detached from any single caller and is only cancelled once every
+// caller waiting on it has gone away, so one impatient client can't abort
+// the result for the others.
+var (
+	scanGroup singleflight.Group
+
+	scanSharesMu sync.Mutex
+	scanShares   = make(map[string]*scanShare)
+)
+
+// scanShare is one in-flight shared scan and the callers waiting on it
+type scanShare struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+type scanOutcome struct {
+	results     []linkResult
+	citationMap *CitationMap
+}
+
+// coalescedScan runs scan for key, or joins an identical scan already in
+// flight. The caller stops waiting when ctx ends; the shared work continues
+// while anyone else still waits.
+func coalescedScan(ctx context.Context, key string, scan func(context.Context) ([]linkResult, *CitationMap, error)) ([]linkResult, *CitationMap, error) {
+	scanSharesMu.Lock()
+	share, ok := scanShares[key]
+	if !ok {
+		// Keep the first caller's values (logger, scan_id) but not its deadline
+		sharedCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		share = &scanShare{ctx: sharedCtx, cancel: cancel}
+		scanShares[key] = share
+	} else {
+		logFor(ctx, "scan").Info("joining in-flight scan", "key", key)
+	}
+	share.waiters++
+	scanSharesMu.Unlock()
+
+	defer func() {
+		scanSharesMu.Lock()
+		share.waiters--
+		if share.waiters == 0 {
+			share.cancel()
+			if scanShares[key] == share {
+				delete(scanShares, key)
+			}
+		}
+		scanSharesMu.Unlock()
+	}()
+
+	// Each share gets its own flight, so a late caller never joins a flight
+	// whose share was already cancelled
+	flight := fmt.Sprintf("%s#%p", key, share)
+	ch := scanGroup.DoChan(flight, func() (any, error) {
+		results, cm, err := scan(share.ctx)
+		return scanOutcome{results, cm}, err
+	})
+
+	select {
+	case res := <-ch:
+		out, _ := res.Val.(scanOutcome)
+		return out.results, out.citationMap, res.Err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import "context"
+
+// linkComplete reports whether a link needs no editor action because its
+// citation already carries an archive: the cited URL is an archive capture
+// itself, or the citation pairs it with an archive-url. With
+// Config.CompleteRequiresLiveArchive that archive must also be known to
+// work: an archive URL needs archive_live (so Config.CheckArchiveURLs), and
+// a paired archive-url is live-checked here. The link's own liveness never
+// matters; a dead link with a working archive is done.
+func linkComplete(ctx context.Context, lr linkResult, cfg Config) bool {
+	if isArchiveURL(lr.URL) {
+		if !cfg.CompleteRequiresLiveArchive {
+			return true
+		}
+		return lr.ArchiveLive != nil && *lr.ArchiveLive
+	}
+	if lr.CitedArchiveURL == "" {
+		return false
+	}
+	if !cfg.CompleteRequiresLiveArchive {
+		return true
+	}
+	live := checkLive(ctx, asciiURL(lr.CitedArchiveURL))
+	alive := isAliveVerdict(computeVerdict(live.Code, live.Status, false))
+	logFor(ctx, "scan").Info("checked cited archive", "url", lr.URL, "cited_archive_url", lr.CitedArchiveURL, "archive_live", alive)
+	return alive
+}
+
+// markCompleteCitations sets Citation.Complete on the citations whose URLs
+// were all checked and found complete. A citation with a link the scan
+// didn't get to (time limit, MaxLinks, added_since) is not complete.
+func markCompleteCitations(cm *CitationMap, results []linkResult, cfg Config) {
+	complete := make(map[string]bool, len(results))
+	for _, lr := range results {
+		complete[normalizeURL(lr.URL, cfg)] = lr.Complete
+	}
+	for i := range cm.Citations {
+		c := &cm.Citations[i]
+		c.Complete = len(c.URLs) > 0
+		for _, u := range c.URLs {
+			if !complete[normalizeURL(u, cfg)] {
+				c.Complete = false
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the IABot-Go release reported by /healthz and the User-Agent
+const Version = "0.1"
+
+// Config holds package-wide tunables for outbound requests.
+// Set it once at startup with SetConfig; handlers read it on every request.
+type Config struct {
+	LiveTimeout      time.Duration // Per-URL live check (HEAD/GET), redirects and fallbacks included
+	ConnectTimeout   time.Duration // Dial plus TLS handshake of any outbound connection, so unreachable hosts fail fast
+	ResponseTimeout  time.Duration // Wait for a live check's response headers once the request is sent
+	WaybackTimeout   time.Duration // Wayback availability lookup
+	SPNTimeout       time.Duration // Save Page Now submission
+	SPNStatusTimeout time.Duration // Save Page Now job status check
+	SPNInterval      time.Duration // Spacing between Save Page Now submissions
+	SPNMaxBatch      int           // Most URLs accepted per SPN submission request
+	SPNMaxWait       time.Duration // Longest SubmitAndWait waits for a capture to finish
+	ScanTimeout      time.Duration // Whole page scan, including all link checks
+	UserAgent        string        // Sent on every outbound request; empty builds it from Contact
+	Contact          string        // Operator email or URL for the User-Agent, as Wikimedia and IA ask of bots
+	MaxLinks         int           // Most unique links checked per scan or batch
+	Workers          int           // Links checked concurrently
+	MaxResponseBytes int64         // Largest response body read from any API
+	MaxRedirects     int           // Redirects a live check follows before reporting "too many redirects"; negative follows none
+	ResultCacheTTL   time.Duration // How long finished scans are reused (and cacheable by browsers); negative disables
+
+	// ResultOrder orders scan results: ResultOrderURL (alphabetical, the
+	// default), ResultOrderCitation (as cited in the article) or
+	// ResultOrderCompletion (as the checks finished).
+	ResultOrder string
+
+	// GETFallbackStatuses are HEAD response codes that make live checks
+	// retry with a ranged GET, for hosts that reject HEAD. nil means 403,
+	// 405 and 501.
+	GETFallbackStatuses []int
+	// AcceptedSnapshotStatuses are the captured HTTP statuses a Wayback
+	// snapshot needs to count as an archive. nil means 200, 203 and 206;
+	// use an empty slice to accept any snapshot and judge its
+	// archive_snapshot_status yourself. See ParseSnapshotStatuses.
+	AcceptedSnapshotStatuses []int
+	// UnknownSnapshotStatus handles snapshots whose captured status the
+	// availability API leaves empty or "-": UnknownSnapshotStatusCDX (look
+	// it up in the CDX index, the default), UnknownSnapshotStatusAccept or
+	// UnknownSnapshotStatusReject. See ParseUnknownSnapshotStatus.
+	UnknownSnapshotStatus string
+
+	// SPNSkipIfArchivedWithin skips SPN submissions for URLs with a Wayback
+	// snapshot newer than this (requests can override with "force").
+	// Negative disables the check.
+	SPNSkipIfArchivedWithin time.Duration
+	// ArchiveStaleAfter marks snapshots older than this as stale, worth
+	// re-capturing. Negative disables the check.
+	ArchiveStaleAfter time.Duration
+
+	// Proxy routes all outbound requests through an http://, https:// or
+	// socks5:// proxy (credentials may be embedded as user:pass@host).
+	// When empty, HTTP_PROXY, HTTPS_PROXY and ALL_PROXY are consulted.
+	Proxy string
+	// NoProxy is a comma-separated list of hosts that bypass the proxy.
+	// When empty, NO_PROXY is consulted.
+	NoProxy string
+
+	// HostHeaders are extra headers for requests to particular hosts, e.g.
+	// an Authorization bearer token for a source or archive you have access
+	// to. A host matches its subdomains; see LoadHostHeaders. The headers
+	// are never logged or sent to another host after a redirect.
+	HostHeaders map[string]http.Header
+
+	// TrackingParams are query parameters ignored when deduplicating URLs
+	// ("utm_*" matches by prefix). nil means the defaults; use an empty
+	// slice to disable stripping.
+	TrackingParams []string
+	// StripTrailingSlash treats /page and /page/ as the same URL
+	StripTrailingSlash bool
+
+	// IgnoredHosts are hosts whose links are never extracted from citations;
+	// a host matches its subdomains and may carry a path prefix
+	// ("wikipedia.org/wiki/"). nil means the Wikimedia defaults; use an
+	// empty slice to extract everything.
+	IgnoredHosts []string
+
+	// ShortenerHosts are URL shorteners (bit.ly, t.co, ...) whose final
+	// destination is reported as a result's ResolvedURL. nil means the
+	// common shorteners; use an empty slice to report none.
+	ShortenerHosts []string
+	// ArchiveResolvedURL looks up and saves the resolved destination of a
+	// shortener link in the archives instead of the shortener URL, which
+	// only keeps working as long as the shortening service does.
+	ArchiveResolvedURL bool
+
+	// MementoFallback queries the Memento aggregator (timetravel.mementoweb.org)
+	// when the Internet Archive has no snapshot, finding captures held by
+	// other archives. Costs one extra request per unarchived link.
+	MementoFallback bool
+
+	// ArchiveProviders are consulted in order for existing snapshots; the
+	// first hit wins. nil means Wayback only; see ParseArchiveProviders.
+	ArchiveProviders []ArchiveProvider
+
+	// ArchiveURLFlavor picks the form of Wayback snapshot URLs in results:
+	// ArchiveFlavorDefault, ArchiveFlavorRaw ("id_", the raw capture) or
+	// ArchiveFlavorNoBanner ("if_", without the Wayback banner)
+	ArchiveURLFlavor string
+
+	// RespectRobots makes live checks honor robots.txt: disallowed URLs are
+	// skipped and crawl-delay spaces out requests to the same host. Off by
+	// default, since link-rot checks usually want to probe regardless.
+	RespectRobots bool
+
+	// DetectParked flags reachable links whose domain looks parked (parking
+	// nameservers or a "domain for sale" page). Off by default: it costs a
+	// DNS lookup and a GET per live link.
+	DetectParked bool
+
+	// StatusVerdicts override the verdict for particular live statuses,
+	// e.g. {401, 401, "dead"} or {403, 403, "dead"} where a community
+	// counts those as dead links. Rules must not overlap; see
+	// ParseStatusVerdicts. nil keeps the built-in policy in computeVerdict.
+	StatusVerdicts []StatusRule
+
+	// AllowedPorts are the only ports live checks connect to. nil means 80
+	// and 443; use an empty slice to allow any port.
+	AllowedPorts []int
+	// AllowPrivateAddresses lets live checks reach loopback, private and
+	// link-local addresses. Leave off on public deployments: otherwise a
+	// crafted article can probe internal services.
+	AllowPrivateAddresses bool
+
+	// FetchTemplates also asks MediaWiki for the templates a page uses, so
+	// scan summaries list its citation templates ({{cite web}} etc.). Off
+	// by default: it enlarges every page fetch.
+	FetchTemplates bool
+
+	// CheckArchiveURLs live-checks cited URLs that are themselves archive
+	// captures instead of assuming they work, so rotten archive links
+	// (a 404ing snapshot, an archive that went away) surface as dead.
+	CheckArchiveURLs bool
+
+	// CompleteRequiresLiveArchive makes a link count as complete only when
+	// its citation's archive is known to work, not merely present: cited
+	// archive-urls are live-checked, and archive URLs need CheckArchiveURLs.
+	// See linkComplete.
+	CompleteRequiresLiveArchive bool
+
+	// InspectBody reads the start of pages that answer 2xx and follows
+	// meta-refresh and JavaScript redirects, which often bounce dead links
+	// to a homepage. Off by default: it costs an extra GET per live link.
+	InspectBody bool
+
+	// LogFormat selects the log output: LogFormatText (default) or
+	// LogFormatJSON for log aggregators
+	LogFormat string
+	// LogLevel is the least severe level logged. The zero value is info;
+	// debug adds every upstream request and raw API responses.
+	LogLevel slog.Level
+
+	// ClientRateLimit caps requests per minute from one client IP to the
+	// endpoints wrapped in RateLimit; negative disables the limit.
+	// ClientBurst is how many may arrive back to back.
+	ClientRateLimit int
+	ClientBurst     int
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// X-Real-IP headers name the real client, for rate limiting and logs.
+	// From any other peer the headers are ignored. See ParseTrustedProxies.
+	TrustedProxies []netip.Prefix
+	// TrustForwardedFor trusts the forwarding headers from every peer, as
+	// if TrustedProxies covered all addresses. Only enable when nothing can
+	// reach the server except through a proxy that sets them.
+	TrustForwardedFor bool
+
+	// APIKey and BasicAuthUser/BasicAuthPassword are the credentials
+	// RequireAuth accepts on the Save Page Now routes (and, with
+	// ProtectScans, the scan endpoints). With neither set those routes are
+	// open to anyone.
+	APIKey            string
+	BasicAuthUser     string
+	BasicAuthPassword string
+	ProtectScans      bool
+
+	// AdminToken enables /admin/cache for requests carrying it as a bearer
+	// token. Empty disables the endpoint.
+	AdminToken string
+}
+
+// projectURL identifies the software in the User-Agent
+const projectURL = "https://github.com/comaeclipse/IABot-Go"
+
+// buildUserAgent returns "IABot-Go/<version> (<contact>; +<project>)", or
+// without the contact if none is configured
+func buildUserAgent(contact string) string {
+	if contact = strings.TrimSpace(contact); contact != "" {
+		return "IABot-Go/" + Version + " (" + contact + "; +" + projectURL + ")"
+	}
+	return "IABot-Go/" + Version + " (+" + projectURL + ")"
+}
+
+// DefaultConfig returns the built-in defaults
+func DefaultConfig() Config {
+	return Config{
+		LiveTimeout:      20 * time.Second,
+		ConnectTimeout:   5 * time.Second,
+		ResponseTimeout:  15 * time.Second,
+		WaybackTimeout:   8 * time.Second,
+		SPNTimeout:       30 * time.Second,
+		SPNStatusTimeout: 10 * time.Second,
+		SPNInterval:      10 * time.Second,
+		SPNMaxBatch:      10,
+		SPNMaxWait:       2 * time.Minute,
+		ScanTimeout:      5 * time.Minute,
+		UserAgent:        buildUserAgent(""),
+		MaxLinks:         50,
+		Workers:          1,
+		MaxResponseBytes: 10 << 20,
+		MaxRedirects:     10,
+		ResultCacheTTL:   10 * time.Minute,
+		TrackingParams:   defaultTrackingParams,
+		IgnoredHosts:     defaultIgnoredHosts,
+		ShortenerHosts:   defaultShortenerHosts,
+		ArchiveProviders: defaultArchiveProviders,
+		ClientRateLimit:  10,
+		ClientBurst:      5,
+
+		SPNSkipIfArchivedWithin: 30 * 24 * time.Hour,
+		ArchiveStaleAfter:       5 * 365 * 24 * time.Hour,
+		GETFallbackStatuses:     []int{http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusNotImplemented},
+		AllowedPorts:            []int{80, 443},
+
+		AcceptedSnapshotStatuses: []int{http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusPartialContent},
+	}
+}
+
+var (
+	configMu sync.RWMutex
+	config   = DefaultConfig()
+)
+
+// SetConfig replaces the package configuration.
+// Zero-valued fields fall back to their defaults.
+func SetConfig(c Config) {
+	d := DefaultConfig()
+	if c.LiveTimeout <= 0 {
+		c.LiveTimeout = d.LiveTimeout
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = d.ConnectTimeout
+	}
+	if c.ResponseTimeout <= 0 {
+		c.ResponseTimeout = d.ResponseTimeout
+	}
+	if c.WaybackTimeout <= 0 {
+		c.WaybackTimeout = d.WaybackTimeout
+	}
+	if c.SPNTimeout <= 0 {
+		c.SPNTimeout = d.SPNTimeout
+	}
+	if c.SPNStatusTimeout <= 0 {
+		c.SPNStatusTimeout = d.SPNStatusTimeout
+	}
+	if c.SPNInterval <= 0 {
+		c.SPNInterval = d.SPNInterval
+	}
+	if c.SPNMaxBatch <= 0 {
+		c.SPNMaxBatch = d.SPNMaxBatch
+	}
+	if c.SPNMaxWait <= 0 {
+		c.SPNMaxWait = d.SPNMaxWait
+	}
+	if c.SPNSkipIfArchivedWithin == 0 {
+		c.SPNSkipIfArchivedWithin = d.SPNSkipIfArchivedWithin
+	}
+	if c.ArchiveStaleAfter == 0 {
+		c.ArchiveStaleAfter = d.ArchiveStaleAfter
+	}
+	if c.ScanTimeout <= 0 {
+		c.ScanTimeout = d.ScanTimeout
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = buildUserAgent(c.Contact)
+	}
+	if c.MaxLinks <= 0 {
+		c.MaxLinks = d.MaxLinks
+	}
+	if c.Workers <= 0 {
+		c.Workers = d.Workers
+	}
+	if c.MaxResponseBytes <= 0 {
+		c.MaxResponseBytes = d.MaxResponseBytes
+	}
+	if c.MaxRedirects == 0 {
+		c.MaxRedirects = d.MaxRedirects
+	}
+	if c.ResultCacheTTL == 0 {
+		c.ResultCacheTTL = d.ResultCacheTTL
+	}
+	if c.ClientRateLimit == 0 {
+		c.ClientRateLimit = d.ClientRateLimit
+	}
+	if c.ClientBurst <= 0 {
+		c.ClientBurst = d.ClientBurst
+	}
+	if c.TrackingParams == nil {
+		c.TrackingParams = d.TrackingParams
+	}
+	if c.GETFallbackStatuses == nil {
+		c.GETFallbackStatuses = d.GETFallbackStatuses
+	}
+	if c.AcceptedSnapshotStatuses == nil {
+		c.AcceptedSnapshotStatuses = d.AcceptedSnapshotStatuses
+	}
+	if c.ArchiveProviders == nil {
+		c.ArchiveProviders = d.ArchiveProviders
+	}
+	if c.AllowedPorts == nil {
+		c.AllowedPorts = d.AllowedPorts
+	}
+	if c.IgnoredHosts == nil {
+		c.IgnoredHosts = d.IgnoredHosts
+	}
+	if c.ShortenerHosts == nil {
+		c.ShortenerHosts = d.ShortenerHosts
+	}
+
+	configMu.Lock()
+	config = c
+	httpClient = newHTTPClient(c)
+	baseLogger = newLogger(c)
+	configMu.Unlock()
+}
+
+// currentConfig returns a copy of the active configuration
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaType returns the lower-case media type of a Content-Type header,
+// without parameters: "application/pdf", "text/html", ...
+func mediaType(header string) string {
+	if mt, _, err := mime.ParseMediaType(header); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(header, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// isHTMLType reports whether body heuristics (meta-refresh, parking page
+// markers) make sense for a response of media type ct. An unknown type
+// gets the benefit of the doubt.
+func isHTMLType(ct string) bool {
+	return ct == "" || strings.Contains(ct, "html")
+}
+
+// responseLength returns the full size of the resource behind resp, or 0 if
+// the server doesn't say. A ranged GET reports it in Content-Range
+// ("bytes 0-0/12345") rather than Content-Length.
+func responseLength(resp *http.Response) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64); err == nil && n > 0 {
+				return n
+			}
+		}
+		return 0
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
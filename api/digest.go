@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// latestSnapshotDigest asks the Wayback CDX API for the newest 200 capture
+// of targetURL and its content digest (base32 SHA-1 of the payload). An
+// empty digest means there is no such capture.
+func latestSnapshotDigest(ctx context.Context, targetURL string) (timestamp, digest string, err error) {
+	v := url.Values{}
+	v.Set("url", targetURL)
+	v.Set("output", "json")
+	v.Set("fl", "timestamp,digest")
+	v.Set("filter", "statuscode:200")
+	v.Set("limit", "-1") // The last capture
+	cfg := currentConfig()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://web.archive.org/cdx/search/cdx?"+v.Encode(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("cdx: HTTP %s", resp.Status)
+	}
+	b, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		return "", "", err
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return "", "", nil
+	}
+	var rows [][]string
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return "", "", fmt.Errorf("cdx: %w", err)
+	}
+	// A header row, then the capture
+	if len(rows) < 2 || len(rows[1]) < 2 {
+		return "", "", nil
+	}
+	return rows[1][0], rows[1][1], nil
+}
+
+// liveDigest fetches targetURL and returns its body's digest in the form
+// CDX uses. The body is asked for without compression, as captures store
+// it; a page served compressed anyway just never matches.
+func liveDigest(ctx context.Context, targetURL string) (string, error) {
+	cfg := currentConfig()
+	logger := logFor(ctx, "spn").With("url", targetURL)
+	ctx, done := withCheckTimeout(withDestinationGuard(ctx), cfg.LiveTimeout, logger)
+	defer done()
+
+	reqURL := asciiURL(targetURL)
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "", err
+	}
+	if err := checkDestination(ctx, u, cfg); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	b, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(b)
+	return base32.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// unchangedSnapshot reports an "unchanged" job when targetURL still serves
+// exactly what its latest Wayback capture holds, so capturing it again
+// would only spend SPN quota. It costs a CDX lookup and a full GET per
+// URL, which is why ScanArchiveRequest.SkipUnchanged is opt-in. Any
+// failure along the way means "capture it".
+func unchangedSnapshot(ctx context.Context, targetURL string) (SPNJob, bool) {
+	logger := logFor(ctx, "spn").With("url", targetURL)
+	timestamp, archived, err := latestSnapshotDigest(ctx, targetURL)
+	if err != nil {
+		logger.Warn("cdx digest lookup failed", "error", err)
+		return SPNJob{}, false
+	}
+	if archived == "" {
+		return SPNJob{}, false
+	}
+	live, err := liveDigest(ctx, targetURL)
+	if err != nil {
+		logger.Info("no live digest", "error", err)
+		return SPNJob{}, false
+	}
+	if live != archived {
+		logger.Debug("content changed since last capture", "timestamp", timestamp)
+		return SPNJob{}, false
+	}
+	logger.Info("skipping: unchanged since last capture", "timestamp", timestamp)
+	return SPNJob{
+		URL:        targetURL,
+		Status:     "unchanged",
+		Timestamp:  timestamp,
+		ArchiveURL: waybackFlavorURL("https://web.archive.org/web/"+timestamp+"/"+targetURL, currentConfig().ArchiveURLFlavor),
+	}, true
+}
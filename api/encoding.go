@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// inspectAcceptEncoding is what body inspection asks servers for. Setting
+// it ourselves turns off the transport's transparent gzip handling, which
+// is skipped for ranged requests anyway, so decodedBody does the work for
+// every response. Brotli is not offered since we can't decode it.
+const inspectAcceptEncoding = "gzip, deflate"
+
+// errUnsupportedEncoding means a body came back in a Content-Encoding we
+// can't decode; callers skip inspection rather than match on garbage
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodedBody reads up to limit decoded bytes of resp's body, undoing a
+// gzip or deflate Content-Encoding. Ranged responses often cut a
+// compressed stream short; whatever decoded cleanly before the cut is
+// returned. Other encodings (br, compress, stacked ones) fail with
+// errUnsupportedEncoding.
+func decodedBody(resp *http.Response, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		r = resp.Body
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "deflate":
+		r = deflateReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedEncoding, enc)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, limit))
+	if errors.Is(err, io.ErrUnexpectedEOF) && len(b) > 0 {
+		return b, nil
+	}
+	return b, err
+}
+
+// deflateReader decodes HTTP "deflate", which should be zlib-wrapped but is
+// sent as a raw deflate stream by some servers
+func deflateReader(body io.Reader) io.Reader {
+	br := bufio.NewReader(body)
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// softNotFound is a page that answers 200 but sends readers elsewhere
+const softNotFound = `<html><head><meta http-equiv="refresh" content="0; url=/not-found"></head><body>Page not found</body></html>`
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func zlibbed(s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func deflated(s string) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodedBody(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 64*1024; i++ {
+		fmt.Fprintf(&sb, "<p>Item %d of a long page</p>", i*7919%10007)
+	}
+	long := sb.String()
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		limit    int64
+		want     string
+		partial  bool // want is a prefix of the decoded body
+		wantErr  error
+	}{
+		{"identity", "", []byte(softNotFound), 1024, softNotFound, false, nil},
+		{"gzip", "gzip", gzipped(softNotFound), 1024, softNotFound, false, nil},
+		{"x-gzip", "X-GZIP", gzipped(softNotFound), 1024, softNotFound, false, nil},
+		{"zlib deflate", "deflate", zlibbed(softNotFound), 1024, softNotFound, false, nil},
+		{"raw deflate", "deflate", deflated(softNotFound), 1024, softNotFound, false, nil},
+		{"limit applies to decoded bytes", "gzip", gzipped(long), 100, long[:100], false, nil},
+		{"truncated gzip", "gzip", gzipped(long)[:4096], 1 << 20, long[:1000], true, nil},
+		{"brotli", "br", []byte("not decoded"), 1024, "", false, errUnsupportedEncoding},
+		{"stacked", "gzip, br", []byte("not decoded"), 1024, "", false, errUnsupportedEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(tt.body))}
+			if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			got, err := decodedBody(resp, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodedBody error = %v; want %v", err, tt.wantErr)
+			}
+			if tt.partial {
+				// Whatever decoded before the cut is kept
+				if len(got) >= len(long) || !strings.HasPrefix(string(got), tt.want) || !strings.HasPrefix(long, string(got)) {
+					t.Errorf("decodedBody = %d bytes; want a prefix of the page starting %q", len(got), tt.want)
+				}
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodedBody = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRefreshGzippedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != inspectAcceptEncoding {
+			t.Errorf("Accept-Encoding = %q; want %q", r.Header.Get("Accept-Encoding"), inspectAcceptEncoding)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(softNotFound))
+	}))
+	defer srv.Close()
+	useConfig(t, testConfig())
+
+	if got, want := checkRefresh(context.Background(), srv.URL+"/page"), srv.URL+"/not-found"; got != want {
+		t.Errorf("checkRefresh = %q; want %q", got, want)
+	}
+}
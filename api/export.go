@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// exportSeparator maps a ?format= value to its field separator.
+// ok is false for formats that aren't tabular exports.
+func exportSeparator(format string) (sep rune, ext string, ok bool) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return ',', "csv", true
+	case "tsv":
+		return '\t', "tsv", true
+	}
+	return 0, "", false
+}
+
+// writeResultsCSV streams results as CSV (or TSV) with a download filename
+// derived from the page title
+func writeResultsCSV(w http.ResponseWriter, page string, results []linkResult, sep rune, ext string) error {
+	setExportHeaders(w, exportFilename(page), sep, ext)
+
+	cw := csv.NewWriter(w)
+	cw.Comma = sep
+	cw.Write([]string{"URL", "LiveCode", "LiveStatus", "Archived", "ArchiveURL", "ArchiveStatus"})
+	for _, lr := range results {
+		cw.Write([]string{
+			lr.URL,
+			strconv.Itoa(lr.LiveCode),
+			lr.LiveStatus,
+			strconv.FormatBool(lr.Archived),
+			lr.ArchiveURL,
+			lr.ArchiveStatus,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// setExportHeaders marks the response as a CSV/TSV download named
+// filename.ext
+func setExportHeaders(w http.ResponseWriter, filename string, sep rune, ext string) {
+	contentType := "text/csv; charset=utf-8"
+	if sep == '\t' {
+		contentType = "text/tab-separated-values; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, ext))
+}
+
+// exportFilename turns a page title into a safe filename
+func exportFilename(page string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(page))
+	if name == "" {
+		name = "scan"
+	}
+	return name
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Live checks fetch whatever URLs an article cites, so on a public
+// deployment a crafted page could point them at internal services. These
+// errors become the link's live status instead of a connection attempt.
+var (
+	errPrivateAddress = errors.New("blocked: private address")
+	errPortNotAllowed = errors.New("blocked: port not allowed")
+)
+
+// cgnatRange is carrier-grade NAT space (RFC 6598), which net.IP.IsPrivate
+// doesn't cover
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+type guardKey struct{}
+
+// withDestinationGuard marks requests made with ctx as fetching untrusted
+// URLs, so redirects they follow are vetted by checkDestination too
+func withDestinationGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardKey{}, true)
+}
+
+func destinationGuarded(ctx context.Context) bool {
+	v, _ := ctx.Value(guardKey{}).(bool)
+	return v
+}
+
+// checkDestination rejects URLs on ports outside cfg.AllowedPorts and, unless
+// cfg.AllowPrivateAddresses is set, hosts that resolve to loopback, private,
+// link-local or unspecified addresses. A host that doesn't resolve is
+// refused with the lookup error. This only fails early with a clear
+// reason: the transport checks the address it actually connects to again
+// (see guardedDial).
+func checkDestination(ctx context.Context, u *url.URL, cfg Config) error {
+	if len(cfg.AllowedPorts) > 0 {
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil || !containsInt(cfg.AllowedPorts, p) {
+			return errPortNotAllowed
+		}
+	}
+
+	if cfg.AllowPrivateAddresses {
+		return nil
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivateIP(ip) {
+			return errPrivateAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if isPrivateIP(a.IP) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// ParseAllowedPorts turns a comma-separated port list ("80,443,8080") into
+// Config.AllowedPorts. "any" allows every port.
+func ParseAllowedPorts(list string) ([]int, error) {
+	if strings.TrimSpace(list) == "any" {
+		return []int{}, nil
+	}
+	var ports []int
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		port, err := strconv.Atoi(s)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", s)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// destinationBlocked reports whether err is a refusal by checkDestination or
+// guardedDial, as opposed to a failure to reach the host
+func destinationBlocked(err error) bool {
+	return errors.Is(err, errPrivateAddress) || errors.Is(err, errPortNotAllowed)
+}
+
+// guardedDial returns the shared transport's DialContext. Connections for
+// requests marked with withDestinationGuard refuse private addresses in
+// Dialer.ControlContext, which sees the address actually being connected
+// to after resolution. checkDestination resolves the host separately, so a
+// DNS answer that changes in between (DNS rebinding) would otherwise get
+// through. Connections to a proxy (addresses in proxies) are exempt: the
+// proxy resolves the target itself, which leaves checkDestination as the
+// only check for proxied requests.
+func guardedDial(d *net.Dialer, proxies map[string]bool, allowPrivate bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if allowPrivate {
+		return d.DialContext
+	}
+	guarded := *d
+	guarded.ControlContext = func(_ context.Context, _, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+			return errPrivateAddress
+		}
+		return nil
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if destinationGuarded(ctx) && !proxies[addr] {
+			return guarded.DialContext(ctx, network, addr)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// isPrivateIP reports whether ip is not a public unicast address
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || cgnatRange.Contains(ip)
+}
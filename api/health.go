@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readyTimeout bounds each upstream reachability probe
+	readyTimeout = 3 * time.Second
+	// readyCacheTTL is how long a readiness result is reused between probes
+	readyCacheTTL = 30 * time.Second
+)
+
+var startTime = time.Now()
+
+// readyTargets are the upstreams a scan can't work without
+var readyTargets = map[string]string{
+	"mediawiki": mediaWikiAPI,
+	"archive":   "https://archive.org/wayback/available",
+}
+
+type readyResult struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+var readyCache struct {
+	mu      sync.Mutex
+	result  readyResult
+	checked time.Time
+}
+
+// HealthHandler handles GET /healthz (liveness)
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"version": Version,
+		"uptime":  time.Since(startTime).Round(time.Second).String(),
+	})
+}
+
+// ReadyHandler handles GET /readyz (readiness). Upstreams are probed at most
+// once per readyCacheTTL.
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	readyCache.mu.Lock()
+	if time.Since(readyCache.checked) >= readyCacheTTL {
+		readyCache.result = probeUpstreams(r.Context())
+		readyCache.checked = time.Now()
+	}
+	result := readyCache.result
+	readyCache.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// probeUpstreams checks every ready target in parallel. Any HTTP response
+// counts as reachable; only transport failures mark a target down.
+func probeUpstreams(ctx context.Context) readyResult {
+	cfg := currentConfig()
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	result := readyResult{Ready: true, Checks: make(map[string]string, len(readyTargets))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, target := range readyTargets {
+		wg.Add(1)
+		go func(name, target string) {
+			defer wg.Done()
+			status := "ok"
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+			if err == nil {
+				req.Header.Set("User-Agent", cfg.UserAgent)
+				var resp *http.Response
+				if resp, err = httpClient.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				status = classifyError(err)
+				result.Ready = false
+			}
+			result.Checks[name] = status
+		}(name, target)
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// scanHistoryPerPage is how many completed scans are kept per page
+	scanHistoryPerPage = 10
+	// scanHistoryMaxPages bounds the store; the page scanned least
+	// recently is dropped first
+	scanHistoryMaxPages = 1000
+)
+
+// storedScan is one completed scan of a page
+type storedScan struct {
+	At      time.Time
+	Results []linkResult
+}
+
+// scanHistoryStore keeps recent completed scans of each page in memory, so
+// later scans can be diffed against them
+type scanHistoryStore struct {
+	mu    sync.Mutex
+	pages map[string][]storedScan // Oldest first
+}
+
+var scanHistory = &scanHistoryStore{pages: make(map[string][]storedScan)}
+
+// record stores a completed scan of page
+func (h *scanHistoryStore) record(page string, at time.Time, results []linkResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	scans := append(h.pages[page], storedScan{At: at, Results: results})
+	if len(scans) > scanHistoryPerPage {
+		scans = scans[len(scans)-scanHistoryPerPage:]
+	}
+	h.pages[page] = scans
+
+	if len(h.pages) > scanHistoryMaxPages {
+		oldest, oldestAt := "", at
+		for p, s := range h.pages {
+			if last := s[len(s)-1].At; last.Before(oldestAt) {
+				oldest, oldestAt = p, last
+			}
+		}
+		delete(h.pages, oldest)
+	}
+}
+
+// find returns the newest stored scan of page taken at or before t (any
+// time if t is zero), skipping the newest skip matches
+func (h *scanHistoryStore) find(page string, t time.Time, skip int) (storedScan, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	scans := h.pages[page]
+	for i := len(scans) - 1; i >= 0; i-- {
+		if !t.IsZero() && scans[i].At.After(t) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		return scans[i], true
+	}
+	return storedScan{}, false
+}
+
+// ScanDiff reports how a page's links changed between two scans
+type ScanDiff struct {
+	Page      string       `json:"page"`
+	Baseline  string       `json:"baseline,omitempty"` // When the earlier scan ran (RFC3339); empty if there was none
+	Latest    string       `json:"latest"`             // When the later scan ran (RFC3339)
+	Added     []linkResult `json:"added"`              // Links only in the later scan
+	Removed   []linkResult `json:"removed"`            // Links only in the earlier scan
+	Changed   []linkChange `json:"changed"`            // Links whose verdict or archive state changed
+	Unchanged int          `json:"unchanged"`
+	Error     *errorBody   `json:"error,omitempty"`
+}
+
+// linkChange is one link's transition between two scans, e.g. alive ->
+// dead or unarchived -> archived
+type linkChange struct {
+	URL          string     `json:"url"`
+	FromVerdict  string     `json:"from_verdict"`
+	ToVerdict    string     `json:"to_verdict"`
+	FromArchived bool       `json:"from_archived"`
+	ToArchived   bool       `json:"to_archived"`
+	Result       linkResult `json:"result"` // The link as of the later scan
+}
+
+// diffScans compares two scans' results, matching links by normalizeURL
+func diffScans(before, after []linkResult, cfg Config) (added, removed []linkResult, changed []linkChange, unchanged int) {
+	added, removed, changed = []linkResult{}, []linkResult{}, []linkChange{}
+	prev := make(map[string]linkResult, len(before))
+	for _, lr := range before {
+		prev[normalizeURL(lr.URL, cfg)] = lr
+	}
+	for _, lr := range after {
+		key := normalizeURL(lr.URL, cfg)
+		old, ok := prev[key]
+		if !ok {
+			added = append(added, lr)
+			continue
+		}
+		delete(prev, key)
+		if old.Verdict == lr.Verdict && old.Archived == lr.Archived {
+			unchanged++
+			continue
+		}
+		changed = append(changed, linkChange{
+			URL:          lr.URL,
+			FromVerdict:  old.Verdict,
+			ToVerdict:    lr.Verdict,
+			FromArchived: old.Archived,
+			ToArchived:   lr.Archived,
+			Result:       lr,
+		})
+	}
+	for _, lr := range before {
+		if _, ok := prev[normalizeURL(lr.URL, cfg)]; ok {
+			removed = append(removed, lr)
+		}
+	}
+	return added, removed, changed, unchanged
+}
+
+// ScanDiffHandler handles GET /api/scan/diff?page=xxx. It scans the page
+// now and compares the result with the newest stored scan, or with the
+// newest one taken at or before &since= (RFC3339). With &rescan=0 it
+// compares the two newest stored scans instead of scanning. Every full
+// (not archive-only) scan of a page is stored, whichever endpoint ran it.
+func ScanDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "Invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	rescan := true
+	if s := r.URL.Query().Get("rescan"); s != "" {
+		if rescan, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "Invalid rescan: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp := ScanDiff{Page: page}
+	var baseline, latest storedScan
+	var hasBaseline bool
+	if rescan {
+		// Pick the baseline first: the new scan is stored as it completes
+		baseline, hasBaseline = scanHistory.find(page, since, 0)
+		results, _, err := scanPageWith(r.Context(), page, scanOptions{})
+		if err != nil {
+			resp.Error = newErrorBody(err)
+			w.Header().Set("Content-Type", "application/json")
+			if isPageMissing(err) {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		latest = storedScan{At: time.Now(), Results: results}
+	} else {
+		var ok bool
+		if latest, ok = scanHistory.find(page, time.Time{}, 0); !ok {
+			http.Error(w, "No stored scans of this page", http.StatusNotFound)
+			return
+		}
+		if since.IsZero() || !since.Before(latest.At) {
+			baseline, hasBaseline = scanHistory.find(page, time.Time{}, 1)
+		} else {
+			baseline, hasBaseline = scanHistory.find(page, since, 0)
+		}
+	}
+
+	resp.Latest = latest.At.UTC().Format(time.RFC3339)
+	if hasBaseline {
+		resp.Baseline = baseline.At.UTC().Format(time.RFC3339)
+	}
+	resp.Added, resp.Removed, resp.Changed, resp.Unchanged = diffScans(baseline.Results, latest.Results, currentConfig())
+	logFor(r.Context(), "scan").Info("diffed scans", "page", page, "baseline", resp.Baseline,
+		"added", len(resp.Added), "removed", len(resp.Removed), "changed", len(resp.Changed))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
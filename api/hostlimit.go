@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostSlotIdle is how long a host's slot is kept after its last request.
+// Dropping it loses nothing the next wait doesn't pass in again.
+const hostSlotIdle = 10 * time.Minute
+
+// hostLimiter spaces out requests to the same host. Each host's interval
+// is set by whoever knows it (e.g. a robots.txt crawl-delay); hosts without
+// an interval are not delayed. Idle hosts are swept while waiting, so the
+// map only holds recently checked hosts.
+type hostLimiter struct {
+	mu        sync.Mutex
+	hosts     map[string]*hostSlot
+	lastSweep time.Time
+}
+
+type hostSlot struct {
+	mu          sync.Mutex
+	lastRequest time.Time
+	minInterval time.Duration
+}
+
+var liveHostLimiter = &hostLimiter{hosts: make(map[string]*hostSlot)}
+
+// wait blocks until a request to host is allowed. A positive interval
+// updates the host's spacing before waiting.
+func (hl *hostLimiter) wait(ctx context.Context, host string, interval time.Duration) error {
+	start := time.Now()
+	defer func() { rateLimitWaitSeconds.WithLabelValues("host").Observe(time.Since(start).Seconds()) }()
+
+	hl.mu.Lock()
+	if now := time.Now(); now.Sub(hl.lastSweep) > time.Minute {
+		hl.sweepLocked(now)
+		hl.lastSweep = now
+	}
+	slot, ok := hl.hosts[host]
+	if !ok {
+		slot = &hostSlot{}
+		hl.hosts[host] = slot
+	}
+	hl.mu.Unlock()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+
+	if interval > 0 {
+		slot.minInterval = interval
+	}
+	elapsed := time.Since(slot.lastRequest)
+	if elapsed < slot.minInterval {
+		select {
+		case <-time.After(slot.minInterval - elapsed):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	slot.lastRequest = time.Now()
+	return nil
+}
+
+// sweepLocked drops the slots of hosts idle for hostSlotIdle. A slot whose
+// lock is held is in use and stays. Caller holds hl.mu.
+func (hl *hostLimiter) sweepLocked(now time.Time) {
+	for host, slot := range hl.hosts {
+		if !slot.mu.TryLock() {
+			continue
+		}
+		if now.Sub(slot.lastRequest) > hostSlotIdle {
+			delete(hl.hosts, host)
+		}
+		slot.mu.Unlock()
+	}
+}
+
+// hostSet is a concurrency-safe set of hosts, bounded so a long-running
+// process can't grow it without limit
+type hostSet struct {
+	mu      sync.Mutex
+	hosts   map[string]time.Time // When each host was added
+	max     int
+	counter cacheCounter
+}
+
+// ParseGETFallbackStatuses turns a comma-separated list of HTTP statuses
+// ("403,405,501") into Config.GETFallbackStatuses. "none" never falls back.
+func ParseGETFallbackStatuses(list string) ([]int, error) {
+	if strings.TrimSpace(list) == "none" {
+		return []int{}, nil
+	}
+	return parseStatusCodes(list)
+}
+
+// getOnlyHosts are hosts that rejected HEAD but answered GET; live checks
+// skip straight to GET for them
+var getOnlyHosts = &hostSet{hosts: make(map[string]time.Time), max: 10000}
+
+func (s *hostSet) has(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.hosts[host]
+	s.counter.record(ok)
+	return ok
+}
+
+func (s *hostSet) add(host string) {
+	if host == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.hosts) >= s.max {
+		return
+	}
+	if _, ok := s.hosts[host]; !ok {
+		s.hosts[host] = time.Now()
+	}
+}
+
+// stats returns the number of hosts and when the earliest was added
+func (s *hostSet) stats() (int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldest time.Time
+	for _, at := range s.hosts {
+		if oldest.IsZero() || at.Before(oldest) {
+			oldest = at
+		}
+	}
+	return len(s.hosts), oldest
+}
+
+// clear empties the set and returns how many hosts it held
+func (s *hostSet) clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.hosts)
+	s.hosts = make(map[string]time.Time)
+	return n
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGETFallbackStatuses(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{"403,405,501", []int{403, 405, 501}, false},
+		{" 403 , 405 ", []int{403, 405}, false},
+		{"none", []int{}, false},
+		{"", nil, false},
+		{"403,abc", nil, true},
+		{"99", nil, true},
+		{"600", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGETFallbackStatuses(tt.list)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseGETFallbackStatuses(%q) = %v, %v; want %v, error %v", tt.list, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLang is used when neither ?lang= nor Accept-Language names a
+// language we have messages for
+const defaultLang = "en"
+
+// messages holds the UI strings of the HTML page by language. English is
+// complete; other languages may leave keys out and fall back to English.
+// Keys ending in "_fmt" take printf arguments: one %d unless the string
+// says otherwise (showing_fmt takes three, the cert titles an issuer and
+// a date). Keys starting with "js_" are for the page's script, which
+// substitutes %d itself.
+var messages = map[string]map[string]string{
+	"en": {
+		"tagline":            "Scan a Wikipedia page for external links and Wayback coverage.",
+		"intro":              "Enter an English Wikipedia page to scan external links.",
+		"page_label":         "Wikipedia page title",
+		"scan":               "Scan",
+		"archive_only":       "Archive gaps only (skip live checks)",
+		"added_since":        "Added since (revision or date)",
+		"added_since_fmt":    "%[2]d links added since %[1]s.",
+		"oldid":              "Revision",
+		"revision_fmt":       "References as of revision %d.",
+		"redirected_fmt":     "Scanned %s (redirected from %s).",
+		"scanned_title_fmt":  "Scanned %s.",
+		"error":              "Error",
+		"links":              "links",
+		"alive":              "alive",
+		"dead":               "dead",
+		"blocked":            "blocked",
+		"archived":           "archived",
+		"unarchived":         "unarchived",
+		"archive_links":      "archive links",
+		"dead_archives":      "dead archive links",
+		"errors":             "errors",
+		"view":               "View",
+		"by_url":             "By URL",
+		"by_citation":        "By Citation",
+		"export":             "Export",
+		"credentials":        "Archive.org Credentials (for Save Page Now)",
+		"save":               "Save",
+		"citations_fmt":      "Citations (%d with URLs)",
+		"results_fmt":        "Results (%d links)",
+		"col_ref":            "Ref",
+		"col_refs":           "Refs",
+		"col_url":            "URL",
+		"col_live":           "Live",
+		"col_wayback":        "Wayback",
+		"citation_view_note": "Note: Citation view shows URLs grouped by reference number. Switch to \"By URL\" for live/archive status.",
+		"archived_link":      "archived",
+		"cited_archive":      "archive in citation",
+		"resolves_to":        "leads to",
+		"not_archived":       "not archived",
+		"archive_button":     "Archive",
+		"reused_title":       "Times this named ref is reused elsewhere in the article",
+		"citation_templates": "Citation templates",
+		"filter":             "Show",
+		"all":                "all",
+		"complete":           "complete",
+		"hide_complete":      "hide complete",
+		"complete_title":     "The citation already carries an archive; nothing to do",
+		"no_matches":         "No links match this filter.",
+		"prev":               "Previous",
+		"next":               "Next",
+		"showing_fmt":        "Showing %d–%d of %d",
+		"cached_fmt":         "Results from a scan %d min ago.",
+		"partial_fmt":        "The scan timed out after %d links; %d more were not checked and are missing below.",
+		"rescan":             "Scan again",
+		// Badges, tooltips and the archive.org credentials form
+		"parked":                  "parked?",
+		"parked_title":            "Domain looks parked or for sale",
+		"size_fmt":                "%d bytes",
+		"cert_expired":            "cert expired",
+		"cert_expired_title_fmt":  "Certificate from %s expired %s",
+		"cert_expiring":           "cert expiring",
+		"cert_expiring_title_fmt": "Certificate from %s expires %s",
+		"off_domain":              "off-domain",
+		"redirected":              "redirected",
+		"stale":                   "stale",
+		"stale_title":             "Old snapshot; consider re-capturing",
+		"access_key":              "Access Key",
+		"secret_key":              "Secret Key",
+		"s3_keys_hint":            "Get your S3 keys from",
+		// Snapshot age, see localizedAge
+		"age_today":      "today",
+		"age_day":        "1 day ago",
+		"age_days_fmt":   "%d days ago",
+		"age_month":      "1 month ago",
+		"age_months_fmt": "%d months ago",
+		"age_year":       "1 year ago",
+		"age_years_fmt":  "%d years ago",
+		// Used by the page's script, see jsMessages
+		"js_saved":             "Saved!",
+		"js_keys_required":     "Both keys required",
+		"js_submitting":        "Submitting...",
+		"js_queued":            "Queued...",
+		"js_queued_fmt":        "Queued (#%d)...",
+		"js_pending_fmt":       "Pending (%ds)...",
+		"js_archived":          "Archived!",
+		"js_recently_archived": "Recently archived",
+		"js_failed":            "Failed",
+		"js_check_failed":      "Check failed",
+		"js_retry":             "Retry",
+		"js_timeout":           "Timeout - check later",
+	},
+	"de": {
+		"tagline":            "Durchsucht eine Wikipedia-Seite nach Weblinks und deren Wayback-Archivierung.",
+		"intro":              "Gib den Titel einer Seite der englischen Wikipedia ein, um ihre Weblinks zu prüfen.",
+		"page_label":         "Titel der Wikipedia-Seite",
+		"scan":               "Prüfen",
+		"archive_only":       "Nur Archivlücken (Erreichbarkeit nicht prüfen)",
+		"added_since":        "Hinzugefügt seit (Version oder Datum)",
+		"added_since_fmt":    "%[2]d Links seit %[1]s hinzugefügt.",
+		"oldid":              "Version",
+		"revision_fmt":       "Belege in Version %d.",
+		"redirected_fmt":     "%s durchsucht (weitergeleitet von %s).",
+		"scanned_title_fmt":  "%s durchsucht.",
+		"error":              "Fehler",
+		"links":              "Links",
+		"alive":              "erreichbar",
+		"dead":               "tot",
+		"blocked":            "blockiert",
+		"archived":           "archiviert",
+		"unarchived":         "nicht archiviert",
+		"archive_links":      "Archivlinks",
+		"dead_archives":      "tote Archivlinks",
+		"errors":             "Fehler",
+		"view":               "Ansicht",
+		"by_url":             "Nach URL",
+		"by_citation":        "Nach Einzelnachweis",
+		"export":             "Export",
+		"credentials":        "Archive.org-Zugangsdaten (für Save Page Now)",
+		"save":               "Speichern",
+		"citations_fmt":      "Einzelnachweise (%d mit URLs)",
+		"results_fmt":        "Ergebnisse (%d Links)",
+		"col_ref":            "Nachweis",
+		"col_refs":           "Nachweise",
+		"col_live":           "Erreichbar",
+		"citation_view_note": "Hinweis: Diese Ansicht gruppiert URLs nach Einzelnachweis. Wechsle zu „Nach URL“ für den Erreichbarkeits- und Archivstatus.",
+		"archived_link":      "archiviert",
+		"cited_archive":      "Archiv im Beleg",
+		"resolves_to":        "führt zu",
+		"not_archived":       "nicht archiviert",
+		"archive_button":     "Archivieren",
+		"reused_title":       "So oft wird dieser benannte Einzelnachweis im Artikel erneut verwendet",
+		"citation_templates": "Zitiervorlagen",
+		"filter":             "Zeige",
+		"all":                "alle",
+		"complete":           "erledigt",
+		"hide_complete":      "erledigte ausblenden",
+		"complete_title":     "Der Beleg hat bereits ein Archiv; nichts zu tun",
+		"no_matches":         "Keine Links entsprechen diesem Filter.",
+		"prev":               "Zurück",
+		"next":               "Weiter",
+		"showing_fmt":        "%d–%d von %d",
+		"cached_fmt":         "Ergebnisse einer Prüfung von vor %d Min.",
+		"partial_fmt":        "Die Prüfung wurde nach %d Links abgebrochen (Zeitüberschreitung); %d weitere wurden nicht geprüft und fehlen unten.",
+		"rescan":             "Neu prüfen",
+		// Badges, tooltips and the archive.org credentials form
+		"parked":                  "geparkt?",
+		"parked_title":            "Die Domain scheint geparkt oder zu verkaufen",
+		"size_fmt":                "%d Bytes",
+		"cert_expired":            "Zertifikat abgelaufen",
+		"cert_expired_title_fmt":  "Zertifikat von %s ist am %s abgelaufen",
+		"cert_expiring":           "Zertifikat läuft ab",
+		"cert_expiring_title_fmt": "Zertifikat von %s läuft am %s ab",
+		"off_domain":              "andere Domain",
+		"redirected":              "weitergeleitet",
+		"stale":                   "veraltet",
+		"stale_title":             "Alter Snapshot; eine neue Archivierung erwägen",
+		"access_key":              "Access Key",
+		"secret_key":              "Secret Key",
+		"s3_keys_hint":            "Deine S3-Schlüssel findest du unter",
+		// Snapshot age, see localizedAge
+		"age_today":      "heute",
+		"age_day":        "vor 1 Tag",
+		"age_days_fmt":   "vor %d Tagen",
+		"age_month":      "vor 1 Monat",
+		"age_months_fmt": "vor %d Monaten",
+		"age_year":       "vor 1 Jahr",
+		"age_years_fmt":  "vor %d Jahren",
+		// Used by the page's script, see jsMessages
+		"js_saved":             "Gespeichert!",
+		"js_keys_required":     "Beide Schlüssel sind nötig",
+		"js_submitting":        "Wird übermittelt …",
+		"js_queued":            "In der Warteschlange …",
+		"js_queued_fmt":        "In der Warteschlange (Nr. %d) …",
+		"js_pending_fmt":       "Ausstehend (%d s) …",
+		"js_archived":          "Archiviert!",
+		"js_recently_archived": "Kürzlich archiviert",
+		"js_failed":            "Fehlgeschlagen",
+		"js_check_failed":      "Abfrage fehlgeschlagen",
+		"js_retry":             "Erneut versuchen",
+		"js_timeout":           "Zeitüberschreitung – später nachsehen",
+	},
+	"fr": {
+		"tagline":            "Analyse les liens externes d'une page Wikipédia et leur couverture Wayback.",
+		"intro":              "Saisissez une page de la Wikipédia anglophone pour analyser ses liens externes.",
+		"page_label":         "Titre de la page Wikipédia",
+		"scan":               "Analyser",
+		"archive_only":       "Lacunes d'archivage uniquement (sans vérifier les liens)",
+		"added_since":        "Ajoutés depuis (version ou date)",
+		"added_since_fmt":    "%[2]d liens ajoutés depuis %[1]s.",
+		"oldid":              "Version",
+		"revision_fmt":       "Références de la version %d.",
+		"redirected_fmt":     "Page analysée : %s (redirigée depuis %s).",
+		"scanned_title_fmt":  "Page analysée : %s.",
+		"error":              "Erreur",
+		"links":              "liens",
+		"alive":              "actifs",
+		"dead":               "morts",
+		"blocked":            "bloqués",
+		"archived":           "archivés",
+		"unarchived":         "non archivés",
+		"archive_links":      "liens d'archive",
+		"dead_archives":      "liens d'archive morts",
+		"errors":             "erreurs",
+		"view":               "Affichage",
+		"by_url":             "Par URL",
+		"by_citation":        "Par référence",
+		"export":             "Exporter",
+		"credentials":        "Identifiants Archive.org (pour Save Page Now)",
+		"save":               "Enregistrer",
+		"citations_fmt":      "Références (%d avec URL)",
+		"results_fmt":        "Résultats (%d liens)",
+		"col_ref":            "Réf.",
+		"col_refs":           "Réf.",
+		"col_live":           "En ligne",
+		"citation_view_note": "Remarque : cet affichage regroupe les URL par numéro de référence. Passez à « Par URL » pour l'état en ligne et l'archivage.",
+		"archived_link":      "archivé",
+		"cited_archive":      "archive dans la référence",
+		"resolves_to":        "mène à",
+		"not_archived":       "non archivé",
+		"archive_button":     "Archiver",
+		"reused_title":       "Nombre de réutilisations de cette référence nommée dans l'article",
+		"citation_templates": "Modèles de citation",
+		"filter":             "Afficher",
+		"all":                "tous",
+		"complete":           "traité",
+		"hide_complete":      "masquer les traités",
+		"complete_title":     "La référence a déjà une archive ; rien à faire",
+		"no_matches":         "Aucun lien ne correspond à ce filtre.",
+		"prev":               "Précédent",
+		"next":               "Suivant",
+		"showing_fmt":        "%d–%d sur %d",
+		"cached_fmt":         "Résultats d'une analyse d'il y a %d min.",
+		"partial_fmt":        "L'analyse a expiré après %d liens ; %d autres n'ont pas été vérifiés et manquent ci-dessous.",
+		"rescan":             "Relancer l'analyse",
+		// Badges, tooltips and the archive.org credentials form
+		"parked":                  "parqué ?",
+		"parked_title":            "Le domaine semble parqué ou à vendre",
+		"size_fmt":                "%d octets",
+		"cert_expired":            "certificat expiré",
+		"cert_expired_title_fmt":  "Certificat de %s expiré le %s",
+		"cert_expiring":           "certificat bientôt expiré",
+		"cert_expiring_title_fmt": "Certificat de %s expirant le %s",
+		"off_domain":              "autre domaine",
+		"redirected":              "redirigé",
+		"stale":                   "ancienne",
+		"stale_title":             "Capture ancienne ; envisagez d'en refaire une",
+		"access_key":              "Clé d'accès",
+		"secret_key":              "Clé secrète",
+		"s3_keys_hint":            "Obtenez vos clés S3 sur",
+		// Snapshot age, see localizedAge
+		"age_today":      "aujourd'hui",
+		"age_day":        "il y a 1 jour",
+		"age_days_fmt":   "il y a %d jours",
+		"age_month":      "il y a 1 mois",
+		"age_months_fmt": "il y a %d mois",
+		"age_year":       "il y a 1 an",
+		"age_years_fmt":  "il y a %d ans",
+		// Used by the page's script, see jsMessages
+		"js_saved":             "Enregistré !",
+		"js_keys_required":     "Les deux clés sont requises",
+		"js_submitting":        "Envoi…",
+		"js_queued":            "En file d'attente…",
+		"js_queued_fmt":        "En file d'attente (n° %d)…",
+		"js_pending_fmt":       "En attente (%d s)…",
+		"js_archived":          "Archivé !",
+		"js_recently_archived": "Archivé récemment",
+		"js_failed":            "Échec",
+		"js_check_failed":      "Échec de la vérification",
+		"js_retry":             "Réessayer",
+		"js_timeout":           "Délai dépassé ; vérifiez plus tard",
+	},
+	"es": {
+		"tagline":            "Analiza los enlaces externos de una página de Wikipedia y su cobertura en Wayback.",
+		"intro":              "Introduce una página de la Wikipedia en inglés para analizar sus enlaces externos.",
+		"page_label":         "Título de la página de Wikipedia",
+		"scan":               "Analizar",
+		"archive_only":       "Solo huecos de archivo (sin comprobar enlaces)",
+		"added_since":        "Añadidos desde (revisión o fecha)",
+		"added_since_fmt":    "%[2]d enlaces añadidos desde %[1]s.",
+		"oldid":              "Revisión",
+		"revision_fmt":       "Referencias de la revisión %d.",
+		"redirected_fmt":     "Se analizó %s (redirigida desde %s).",
+		"scanned_title_fmt":  "Se analizó %s.",
+		"error":              "Error",
+		"links":              "enlaces",
+		"alive":              "activos",
+		"dead":               "rotos",
+		"blocked":            "bloqueados",
+		"archived":           "archivados",
+		"unarchived":         "sin archivar",
+		"archive_links":      "enlaces de archivo",
+		"dead_archives":      "enlaces de archivo rotos",
+		"errors":             "errores",
+		"view":               "Vista",
+		"by_url":             "Por URL",
+		"by_citation":        "Por referencia",
+		"export":             "Exportar",
+		"credentials":        "Credenciales de Archive.org (para Save Page Now)",
+		"save":               "Guardar",
+		"citations_fmt":      "Referencias (%d con URL)",
+		"results_fmt":        "Resultados (%d enlaces)",
+		"col_ref":            "Ref.",
+		"col_refs":           "Refs.",
+		"col_live":           "En línea",
+		"citation_view_note": "Nota: esta vista agrupa las URL por número de referencia. Cambia a «Por URL» para ver el estado en línea y de archivo.",
+		"archived_link":      "archivado",
+		"cited_archive":      "archivo en la cita",
+		"resolves_to":        "lleva a",
+		"not_archived":       "sin archivar",
+		"archive_button":     "Archivar",
+		"reused_title":       "Veces que se reutiliza esta referencia con nombre en el artículo",
+		"citation_templates": "Plantillas de cita",
+		"filter":             "Mostrar",
+		"all":                "todos",
+		"complete":           "resuelta",
+		"hide_complete":      "ocultar resueltas",
+		"complete_title":     "La referencia ya tiene un archivo; no hay nada que hacer",
+		"no_matches":         "Ningún enlace coincide con este filtro.",
+		"prev":               "Anterior",
+		"next":               "Siguiente",
+		"showing_fmt":        "%d–%d de %d",
+		"cached_fmt":         "Resultados de un análisis de hace %d min.",
+		"partial_fmt":        "El análisis agotó el tiempo tras %d enlaces; otros %d no se comprobaron y faltan abajo.",
+		"rescan":             "Volver a analizar",
+		// Badges, tooltips and the archive.org credentials form
+		"parked":                  "¿aparcado?",
+		"parked_title":            "El dominio parece aparcado o en venta",
+		"size_fmt":                "%d bytes",
+		"cert_expired":            "certificado caducado",
+		"cert_expired_title_fmt":  "El certificado de %s caducó el %s",
+		"cert_expiring":           "certificado por caducar",
+		"cert_expiring_title_fmt": "El certificado de %s caduca el %s",
+		"off_domain":              "otro dominio",
+		"redirected":              "redirigido",
+		"stale":                   "antigua",
+		"stale_title":             "Captura antigua; considera volver a capturarla",
+		"access_key":              "Clave de acceso",
+		"secret_key":              "Clave secreta",
+		"s3_keys_hint":            "Consigue tus claves S3 en",
+		// Snapshot age, see localizedAge
+		"age_today":      "hoy",
+		"age_day":        "hace 1 día",
+		"age_days_fmt":   "hace %d días",
+		"age_month":      "hace 1 mes",
+		"age_months_fmt": "hace %d meses",
+		"age_year":       "hace 1 año",
+		"age_years_fmt":  "hace %d años",
+		// Used by the page's script, see jsMessages
+		"js_saved":             "¡Guardado!",
+		"js_keys_required":     "Se necesitan las dos claves",
+		"js_submitting":        "Enviando…",
+		"js_queued":            "En cola…",
+		"js_queued_fmt":        "En cola (n.º %d)…",
+		"js_pending_fmt":       "Pendiente (%d s)…",
+		"js_archived":          "¡Archivado!",
+		"js_recently_archived": "Archivado recientemente",
+		"js_failed":            "Error",
+		"js_check_failed":      "Error al comprobar",
+		"js_retry":             "Reintentar",
+		"js_timeout":           "Tiempo agotado; compruébalo más tarde",
+	},
+}
+
+// requestLang picks the UI language for r: an explicit ?lang= wins, then
+// the highest-weighted Accept-Language entry we have messages for. Region
+// subtags are ignored ("de-AT" is "de").
+func requestLang(r *http.Request) string {
+	if lang := baseLang(r.URL.Query().Get("lang")); messages[lang] != nil {
+		return lang
+	}
+
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && q > 0 {
+			prefs = append(prefs, weighted{baseLang(tag), q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if messages[p.lang] != nil {
+			return p.lang
+		}
+	}
+	return defaultLang
+}
+
+// baseLang lowercases a language tag and drops any region or script subtag
+func baseLang(tag string) string {
+	tag, _, _ = strings.Cut(tag, "-")
+	tag, _, _ = strings.Cut(tag, "_")
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// translations returns the UI strings for lang, with English filling any
+// gaps
+func translations(lang string) map[string]string {
+	t := make(map[string]string, len(messages[defaultLang]))
+	for k, v := range messages[defaultLang] {
+		t[k] = v
+	}
+	for k, v := range messages[lang] {
+		t[k] = v
+	}
+	return t
+}
+
+// jsMessages picks the "js_" strings out of t for the page's script, keyed
+// without the prefix
+func jsMessages(t map[string]string) map[string]string {
+	js := make(map[string]string)
+	for k, v := range t {
+		if name, ok := strings.CutPrefix(k, "js_"); ok {
+			js[name] = v
+		}
+	}
+	return js
+}
+
+// localizedAge is archiveAge in the language of t, a translations map
+func localizedAge(t map[string]string, ts, now time.Time) string {
+	n, unit := ageUnits(ts, now)
+	switch n {
+	case 0:
+		return t["age_today"]
+	case 1:
+		return t["age_"+unit]
+	}
+	return fmt.Sprintf(t["age_"+unit+"s_fmt"], n)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessagesMatchEnglish(t *testing.T) {
+	en := messages[defaultLang]
+	for lang, msgs := range messages {
+		for k, v := range msgs {
+			want, ok := en[k]
+			if !ok {
+				t.Errorf("%s: %q has no English message", lang, k)
+				continue
+			}
+			if got, wantN := strings.Count(v, "%"), strings.Count(want, "%"); got != wantN {
+				t.Errorf("%s: %q has %d verbs; English has %d", lang, k, got, wantN)
+			}
+		}
+	}
+}
+
+func TestLocalizedAge(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago    time.Duration
+		en, de string
+	}{
+		{time.Hour, "today", "heute"},
+		{24 * time.Hour, "1 day ago", "vor 1 Tag"},
+		{3 * 24 * time.Hour, "3 days ago", "vor 3 Tagen"},
+		{45 * 24 * time.Hour, "1 month ago", "vor 1 Monat"},
+		{100 * 24 * time.Hour, "3 months ago", "vor 3 Monaten"},
+		{400 * 24 * time.Hour, "1 year ago", "vor 1 Jahr"},
+		{800 * 24 * time.Hour, "2 years ago", "vor 2 Jahren"},
+	}
+	for _, tt := range tests {
+		ts := now.Add(-tt.ago)
+		if got := archiveAge(ts, now); got != tt.en {
+			t.Errorf("archiveAge(-%v) = %q; want %q", tt.ago, got, tt.en)
+		}
+		if got := localizedAge(translations("en"), ts, now); got != tt.en {
+			t.Errorf("localizedAge(en, -%v) = %q; want %q", tt.ago, got, tt.en)
+		}
+		if got := localizedAge(translations("de"), ts, now); got != tt.de {
+			t.Errorf("localizedAge(de, -%v) = %q; want %q", tt.ago, got, tt.de)
+		}
+	}
+}
+
+func TestHandlerTranslatesBadgesAndScript(t *testing.T) {
+	var base string
+	stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/w/api.php":
+			fmt.Fprintf(w, `{"parse": {"title": "Foo", "revid": 5, "wikitext": {"*": %q}}}`, "<ref>"+base+"/ok</ref>")
+		case "/wayback/available":
+			w.Write([]byte(`{"archived_snapshots": {"closest": {"available": true, "url": "http://web.archive.org/web/20100101000000/http://example.com/", "timestamp": "20100101000000", "status": "200"}}}`))
+		}
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	base = srv.URL
+
+	rec := httptest.NewRecorder()
+	Handler(rec, httptest.NewRequest(http.MethodGet, "/?page=Foo&lang=de", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+
+	de := messages["de"]
+	snapshot := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, want := range []string{
+		"(" + localizedAge(translations("de"), snapshot, time.Now()) + ")",
+		">" + de["stale"] + "<",
+		`placeholder="` + de["access_key"] + `"`,
+		de["s3_keys_hint"],
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+	for _, english := range []string{"years ago", ">stale<", "Get your S3 keys", "'Submitting...'", "'Archived!'"} {
+		if strings.Contains(body, english) {
+			t.Errorf("German page contains %q", english)
+		}
+	}
+	if !strings.Contains(body, `"archived":"Archiviert!"`) {
+		t.Error("script lacks the German SPN strings")
+	}
+}
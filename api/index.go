@@ -1,17 +1,19 @@
 package handler
 
 import (
+    "bytes"
     "context"
     "embed"
     "encoding/json"
+    "errors"
     "fmt"
     "html/template"
     "io"
-    "log"
     "net/http"
     "net/url"
     "regexp"
     "sort"
+    "strconv"
     "strings"
     "time"
 )
@@ -19,29 +21,81 @@ import (
 //go:embed templates/index.html
 var tmplFS embed.FS
 
+// indexTmpl is parsed once at init; a broken template fails at startup
+// rather than on the first request
+var indexTmpl = template.Must(template.ParseFS(tmplFS, "templates/index.html"))
+
 type pageData struct {
-    Title     string
-    Message   string
-    Query     string
-    Results   []linkResult
-    Citations []Citation // Citations with URLs for citation-first view
-    ViewMode  string     // "url" or "citation"
-    Error     string
+    Title       string
+    Message     string
+    Query       string
+    Results     []linkResult
+    Summary     ScanSummary
+    Pager       *pager // Filter and paging controls for Results
+    CachedAge   int          // Minutes since the shown scan ran, when it came from the result cache
+    RefreshURL  template.URL // This page with ?refresh=1
+    ViewURLs    map[string]template.URL // This scan in each view, by ViewMode
+    ExportURLs  map[string]template.URL // This scan as "csv" and "tsv"
+    Checked     int          // Links checked before a scan timed out; set with Unchecked
+    Unchecked   int          // Links a timed-out scan didn't get to (0 for a complete scan)
+    Citations   []Citation // Citations with URLs for citation-first view
+    ViewMode    string     // "url" or "citation"
+    ArchiveOnly bool       // Live checks were skipped
+    AddedSince  string     // ?added_since= as given: only links added after this revision or date were scanned
+    OldID       string     // ?oldid= as given: scan this revision instead of the current one
+    Revision    int64      // Revision the shown scan read
+    ScannedTitle   string  // Title the scan read, when MediaWiki resolved Query elsewhere
+    RedirectedFrom string  // Redirect followed to ScannedTitle
+    ArchiveResolved bool   // Config.ArchiveResolvedURL: archive buttons save shortener destinations
+    Error       string
+    Lang        string            // UI language, see requestLang
+    LangParam   string            // Explicit ?lang=, carried over into links
+    T           map[string]string // UI strings in Lang
+    JS          map[string]string // Strings the page's script shows, see jsMessages
 }
 
 type linkResult struct {
-    URL             string
-    LiveCode        int
-    LiveStatus      string
-    Archived        bool
-    ArchiveURL      string
-    ArchiveStatus   string
-    CitationNumbers []int // Which citations reference this URL
+    URL             string `json:"url"`
+    LiveCode        int    `json:"live_code"`
+    LiveStatus      string `json:"live_status"`   // Human-readable, for display
+    LiveCategory    string `json:"live_category"` // Stable code for programs, see classifyStatus
+    LiveSkipped     bool   `json:"live_skipped,omitempty"` // Archive-only scan: no live check was made
+    Archived        bool   `json:"archived"`
+    ArchiveURL      string `json:"archive_url,omitempty"`
+    ArchiveStatus   string `json:"archive_status"`
+    ArchiveSource   string `json:"archive_source,omitempty"` // Archive the snapshot came from ("wayback", "archive.today", ...)
+    ArchiveSnapshotStatus int `json:"archive_snapshot_status,omitempty"` // Captured HTTP status of the closest Wayback snapshot, accepted or not
+    ArchiveLive     *bool  `json:"archive_live,omitempty"` // The cited URL is an archive and still works (Config.CheckArchiveURLs); live_* describe it
+    CitationNumbers []int  `json:"citation_numbers,omitempty"` // Which citations reference this URL
+    CitationContext string `json:"citation_context,omitempty"` // Snippet of the first citing ref, e.g. "Smith 2019, BBC News"
+    CitedArchiveURL string `json:"cited_archive_url,omitempty"` // archive-url the citation already pairs with this URL
+    ResolvedURL     string `json:"resolved_url,omitempty"`      // Where a URL shortener link leads (Config.ShortenerHosts)
+
+    RedirectChain       []string `json:"redirect_chain,omitempty"`        // Each hop as "<status> <url>", ending at the final response
+    RedirectedOffDomain bool     `json:"redirected_off_domain,omitempty"` // Final landing host differs from the original host
+    Parked              bool     `json:"parked,omitempty"`                // Domain looks parked or for sale (Config.DetectParked)
+    RefreshTarget       string   `json:"refresh_target,omitempty"`        // Meta-refresh or JavaScript redirect target (Config.InspectBody)
+    ContentType         string   `json:"content_type,omitempty"`          // Media type the live check got, e.g. "application/pdf"
+    ContentLength       int64    `json:"content_length,omitempty"`        // Size of the resource in bytes, when the server states it
+
+    Verdict string `json:"verdict"` // Combined live + archive answer, see computeVerdict
+    Complete bool  `json:"complete,omitempty"` // The citation already carries an archive, so nothing needs doing; see linkComplete
+
+    ArchiveTimestamp string `json:"archive_timestamp,omitempty"` // Snapshot capture time (RFC3339)
+    ArchiveAge       string `json:"archive_age,omitempty"`       // Human-readable snapshot age, e.g. "3 years ago"
+    ArchiveStale     bool   `json:"archive_stale,omitempty"`     // Snapshot is older than Config.ArchiveStaleAfter
+
+    TLSIssuer       string     `json:"tls_issuer,omitempty"`        // Issuer of the site's certificate (HTTPS links)
+    CertExpiry      *time.Time `json:"cert_expiry,omitempty"`       // Certificate NotAfter date
+    TLSExpired      bool       `json:"tls_expired,omitempty"`       // Certificate is past its expiry date
+    TLSExpiringSoon bool       `json:"tls_expiring_soon,omitempty"` // Certificate expires within 30 days
 }
 
 type apiError struct {
     msg     string
     status  int
+    code    string // MediaWiki error code, e.g. "missingtitle"
+    info    string // MediaWiki's own description of the error
     payload string
 }
 
@@ -58,9 +112,15 @@ func (e *apiError) Error() string {
 
 // Citation represents a single <ref> tag in the wikitext
 type Citation struct {
-	Number int      // Assigned citation number (1-based)
-	Name   string   // ref name attribute (empty if unnamed)
-	URLs   []string // Extracted URLs from this citation
+	Number  int           // Assigned citation number (1-based)
+	Name    string        // ref name attribute (empty if unnamed)
+	URLs    []string      // Extracted URLs from this citation
+	Context string        // Readable snippet of the ref: cite title and work, or its leading text
+	Sources []CitedSource // Template url/archive-url pairs, telling archived sources apart from extra live links
+
+	ReuseCount int // Times the named ref is cited again (<ref name="foo"/>) beyond its definition
+
+	Complete bool // After a scan: every URL of the citation is complete (see linkComplete)
 }
 
 // CitationMap provides bidirectional lookup between citations and URLs
@@ -68,21 +128,53 @@ type CitationMap struct {
 	Citations     []Citation       // All citations with URLs, in order
 	URLToCitation map[string][]int // URL -> list of citation numbers that use it
 	NameToNumber  map[string]int   // ref name -> citation number (for reuse tracking)
+	Ignored       []string         // URLs in refs skipped by Config.IgnoredHosts
+
+	Templates []TemplateUsage // Citation templates on the page (Config.FetchTemplates only)
+	Revision  int64           // Revision ID the wikitext was taken from
+
+	Title          string // Title the wikitext was taken from, as MediaWiki spells it
+	RedirectedFrom string // Redirect the scan followed to reach Title, if any
 }
 
+// templateURLParams are the template parameters URLs are taken from, in
+// any template ({{cite web}}, {{cite news}}, {{webarchive}}, ...)
+var templateURLParams = []string{"url", "archive-url", "archiveurl"}
+
 // Regex patterns for parsing
 var (
 	// Match <ref> tags: <ref name="foo">content</ref> or <ref name="foo"/>
-	// Group 1: full name attribute, Group 2: name value, Group 3: content (if not self-closing)
-	refPattern = regexp.MustCompile(`(?i)<ref(\s+name\s*=\s*["']?([^"'>\s]+)["']?)?\s*(?:/>|>([\s\S]*?)</ref>)`)
+	// Group 1: full name attribute, Group 2: name value, Group 3: content (if not self-closing).
+	// The name is matched lazily so the "/" of an unquoted <ref name=foo/> closes the tag.
+	refPattern = regexp.MustCompile(`(?i)<ref(\s+name\s*=\s*["']?([^"'>\s]+?)["']?)?\s*(?:/>|>([\s\S]*?)</ref>)`)
 
 	// Match URLs directly in text
 	urlPattern = regexp.MustCompile(`https?://[^\s<>"\]\|{}\[\]]+`)
 
-	// Match URLs in templates like |url=... or |archive-url=...
-	templateURLPattern = regexp.MustCompile(`\|\s*(?:url|archive-url|archiveurl)\s*=\s*([^\s\|\}]+)`)
+	// Match URLs in templates like |url=... or |archive-url=..., for any
+	// parameter in templateURLParams
+	templateURLPattern = regexp.MustCompile(`\|\s*(?:` + strings.Join(templateURLParams, "|") + `)\s*=\s*([^\s\|\}]+)`)
+
+	// Match external links like [http://example.com Display text], including
+	// protocol-relative [//example.com text]. Group 1: URL (up to the first space)
+	bracketLinkPattern = regexp.MustCompile(`\[((?:https?:)?//[^\s\[\]<>"]+)(?:\s[^\]]*)?\]`)
+
+	// Patterns for citationContext. Group 1: the parameter value
+	citeTitlePattern = regexp.MustCompile(`\|\s*title\s*=\s*([^|}]+)`)
+	citeWorkPattern  = regexp.MustCompile(`\|\s*(?:work|website|newspaper|journal|publisher)\s*=\s*([^|}]+)`)
+	templatePattern  = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	wikiLinkPattern  = regexp.MustCompile(`\[\[(?:[^|\]]*\|)?([^\]]*)\]\]`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]*>`)
+
+	// Inactive markup whose URLs are not citations: HTML comments (an
+	// unclosed one runs to the end) and <nowiki>...</nowiki> blocks
+	commentPattern = regexp.MustCompile(`(?s)<!--.*?(?:-->|$)`)
+	nowikiPattern  = regexp.MustCompile(`(?is)<nowiki\s*>.*?</nowiki\s*>`)
 )
 
+// citationContextMax is the longest snippet citationContext returns, in runes
+const citationContextMax = 100
+
 // ParseCitations extracts citations from Wikipedia wikitext and builds a CitationMap
 func ParseCitations(wikitext string) *CitationMap {
 	cm := &CitationMap{
@@ -92,7 +184,9 @@ func ParseCitations(wikitext string) *CitationMap {
 	}
 
 	matches := refPattern.FindAllStringSubmatch(wikitext, -1)
+	dates := articleDateOrder(wikitext)
 	citationNum := 0
+	reuses := make(map[string]int) // ref name -> reuse count, wherever the reuse appears
 
 	for _, match := range matches {
 		// match[0] = full match
@@ -105,20 +199,24 @@ func ParseCitations(wikitext string) *CitationMap {
 
 		// Handle self-closing refs that reference existing named refs
 		if content == "" && name != "" {
-			// This is a reuse like <ref name="foo"/> - skip, same number as original
+			// This is a reuse like <ref name="foo"/> - same number as the
+			// original, which may be defined later in the article
+			reuses[name]++
 			continue
 		}
 
 		// Handle named refs that were already defined
 		if name != "" {
 			if _, exists := cm.NameToNumber[name]; exists {
-				// Already seen this named ref, skip
+				// Repeated definition, rendered as another reuse
+				reuses[name]++
 				continue
 			}
 		}
 
 		// Extract URLs from the ref content
-		urls := extractURLsFromContent(content)
+		urls, ignored := extractURLsFromContent(content)
+		cm.Ignored = append(cm.Ignored, ignored...)
 
 		// Only create citation if it has URLs (per user request)
 		if len(urls) == 0 {
@@ -132,9 +230,11 @@ func ParseCitations(wikitext string) *CitationMap {
 
 		citationNum++
 		citation := Citation{
-			Number: citationNum,
-			Name:   name,
-			URLs:   urls,
+			Number:  citationNum,
+			Name:    name,
+			URLs:    urls,
+			Context: citationContext(content),
+			Sources: citedSources(content, dates),
 		}
 
 		if name != "" {
@@ -149,20 +249,50 @@ func ParseCitations(wikitext string) *CitationMap {
 		}
 	}
 
+	for i := range cm.Citations {
+		if name := cm.Citations[i].Name; name != "" {
+			cm.Citations[i].ReuseCount = reuses[name]
+		}
+	}
 	return cm
 }
 
 // extractURLsFromContent extracts URLs from ref content, handling both direct URLs
-// and template parameters like |url=...
-func extractURLsFromContent(content string) []string {
+// and template parameters like |url=... URLs matching Config.IgnoredHosts are
+// returned separately.
+func extractURLsFromContent(content string) (urls, skipped []string) {
+	content = stripInactiveMarkup(content)
 	seen := make(map[string]struct{})
-	var urls []string
+	ignored := currentConfig().IgnoredHosts
+	isIgnored := func(u string) bool {
+		if !isIgnoredURL(u, ignored) {
+			return false
+		}
+		if _, ok := seen[u]; !ok {
+			seen[u] = struct{}{}
+			skipped = append(skipped, u)
+		}
+		return true
+	}
 
 	// Extract direct URLs
 	directMatches := urlPattern.FindAllString(content, -1)
 	for _, u := range directMatches {
 		u = cleanURL(u)
-		if u != "" && !isIgnoredURL(u) {
+		if u != "" && !isIgnored(u) {
+			if _, ok := seen[u]; !ok {
+				seen[u] = struct{}{}
+				urls = append(urls, u)
+			}
+		}
+	}
+
+	// Extract URLs from bracketed external links ([url text]); the direct
+	// pattern misses protocol-relative ones
+	bracketMatches := bracketLinkPattern.FindAllStringSubmatch(content, -1)
+	for _, match := range bracketMatches {
+		u := cleanURL(withScheme(match[1]))
+		if u != "" && !isIgnored(u) {
 			if _, ok := seen[u]; !ok {
 				seen[u] = struct{}{}
 				urls = append(urls, u)
@@ -174,8 +304,8 @@ func extractURLsFromContent(content string) []string {
 	templateMatches := templateURLPattern.FindAllStringSubmatch(content, -1)
 	for _, match := range templateMatches {
 		if len(match) > 1 {
-			u := cleanURL(match[1])
-			if u != "" && strings.HasPrefix(u, "http") && !isIgnoredURL(u) {
+			u := cleanURL(withScheme(match[1]))
+			if u != "" && strings.HasPrefix(u, "http") && !isIgnored(u) {
 				if _, ok := seen[u]; !ok {
 					seen[u] = struct{}{}
 					urls = append(urls, u)
@@ -184,47 +314,168 @@ func extractURLsFromContent(content string) []string {
 		}
 	}
 
-	return urls
+	return urls, skipped
+}
+
+// stripInactiveMarkup removes HTML comments and nowiki blocks, whose
+// contents MediaWiki never renders as links. Comments go first, so a
+// commented-out <nowiki> can't hide what follows it.
+func stripInactiveMarkup(content string) string {
+	content = commentPattern.ReplaceAllString(content, "")
+	return nowikiPattern.ReplaceAllString(content, "")
+}
+
+// citationContext summarizes ref content for display. It prefers the cite
+// template's title and work ("Smith 2019, BBC News"); otherwise it strips
+// templates, links and markup from the text and keeps the first
+// citationContextMax runes.
+func citationContext(content string) string {
+	// Resolve wikilinks first; their pipes would cut parameter values short
+	content = wikiLinkPattern.ReplaceAllString(content, "$1")
+
+	var parts []string
+	if m := citeTitlePattern.FindStringSubmatch(content); m != nil {
+		parts = append(parts, stripWikiMarkup(m[1]))
+	}
+	if m := citeWorkPattern.FindStringSubmatch(content); m != nil {
+		parts = append(parts, stripWikiMarkup(m[1]))
+	}
+	text := strings.Join(parts, ", ")
+
+	if text == "" {
+		// Drop templates innermost-first so nested ones disappear too
+		for prev := ""; prev != content; {
+			prev = content
+			content = templatePattern.ReplaceAllString(content, "")
+		}
+		text = stripWikiMarkup(content)
+	}
+
+	if r := []rune(text); len(r) > citationContextMax {
+		text = strings.TrimSpace(string(r[:citationContextMax])) + "…"
+	}
+	return text
+}
+
+// stripWikiMarkup reduces wikitext to plain text: [[a|b]] -> b,
+// [url text] -> text, bare URLs, bold/italic quotes and HTML tags removed
+func stripWikiMarkup(s string) string {
+	s = wikiLinkPattern.ReplaceAllString(s, "$1")
+	s = bracketLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		_, text, _ := strings.Cut(strings.Trim(m, "[]"), " ")
+		return text
+	})
+	s = urlPattern.ReplaceAllString(s, "")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "''", "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// withScheme turns protocol-relative URLs (//example.com) and bare
+// www. hosts into https URLs; anything else is returned unchanged
+func withScheme(u string) string {
+	switch {
+	case strings.HasPrefix(u, "//"):
+		return "https:" + u
+	case len(u) > 4 && strings.EqualFold(u[:4], "www."):
+		return "https://" + u
+	}
+	return u
 }
 
 // cleanURL removes trailing punctuation and normalizes the URL
 func cleanURL(u string) string {
 	u = strings.TrimSpace(u)
 
-	// Remove common trailing characters that aren't part of URLs
-	for strings.HasSuffix(u, ".") || strings.HasSuffix(u, ",") ||
-		strings.HasSuffix(u, ";") || strings.HasSuffix(u, ":") ||
-		strings.HasSuffix(u, ")") || strings.HasSuffix(u, "]") ||
-		strings.HasSuffix(u, "'") || strings.HasSuffix(u, "\"") {
+	// Remove common trailing characters that aren't part of URLs. A closing
+	// paren or bracket is only stripped when unbalanced, so links such as
+	// .../Foo_(disambiguation) survive intact.
+	for u != "" {
+		switch u[len(u)-1] {
+		case '.', ',', ';', ':', '\'', '"':
+		case ')':
+			if strings.Count(u, "(") >= strings.Count(u, ")") {
+				return u
+			}
+		case ']':
+			if strings.Count(u, "[") >= strings.Count(u, "]") {
+				return u
+			}
+		default:
+			return u
+		}
 		u = u[:len(u)-1]
 	}
 
 	return u
 }
 
-// isIgnoredURL returns true for URLs we should skip (internal wiki links, etc.)
-func isIgnoredURL(u string) bool {
-	lower := strings.ToLower(u)
+// defaultIgnoredHosts are skipped unless Config.IgnoredHosts overrides them:
+// internal wiki links and Wikimedia/Wikidata infrastructure
+var defaultIgnoredHosts = []string{
+	"wikipedia.org/wiki/",
+	"wikimedia.org",
+	"wikidata.org",
+}
 
-	// Skip Wikipedia internal links
-	if strings.Contains(lower, "wikipedia.org/wiki/") ||
-		strings.Contains(lower, "wikimedia.org") ||
-		strings.Contains(lower, "wikidata.org") {
-		return true
+// isIgnoredURL returns true for URLs we should skip (internal wiki links, etc.).
+// Each pattern is a host, matching itself and its subdomains, optionally
+// followed by a path prefix ("wikipedia.org/wiki/").
+func isIgnoredURL(u string, patterns []string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, p := range patterns {
+		domain, path, _ := strings.Cut(strings.ToLower(p), "/")
+		if hostMatches(host, domain) && (path == "" || strings.HasPrefix(parsed.EscapedPath(), "/"+path)) {
+			return true
+		}
 	}
-
 	return false
 }
 
-// GetUniqueURLs returns all unique URLs from the citation map
+// GetUniqueURLs returns all unique URLs from the citation map, sorted, so
+// the same wikitext always yields the same list
 func (cm *CitationMap) GetUniqueURLs() []string {
 	urls := make([]string, 0, len(cm.URLToCitation))
 	for url := range cm.URLToCitation {
 		urls = append(urls, url)
 	}
+	sort.Strings(urls)
 	return urls
 }
 
+// CitedArchive returns the archive-url that one of the listed citations
+// pairs with url in a citation template, if any
+func (cm *CitationMap) CitedArchive(url string, numbers []int) string {
+	for _, c := range cm.Citations {
+		if !containsInt(numbers, c.Number) {
+			continue
+		}
+		for _, s := range c.Sources {
+			if s.URL == url && s.ArchiveURL != "" {
+				return s.ArchiveURL
+			}
+		}
+	}
+	return ""
+}
+
+// ContextFor returns the context snippet of the first listed citation that
+// has one
+func (cm *CitationMap) ContextFor(numbers []int) string {
+	for _, n := range numbers {
+		for _, c := range cm.Citations {
+			if c.Number == n && c.Context != "" {
+				return c.Context
+			}
+		}
+	}
+	return ""
+}
+
 // GetCitationNumbers returns the citation numbers that reference a given URL
 func (cm *CitationMap) GetCitationNumbers(url string) []int {
 	return cm.URLToCitation[url]
@@ -232,13 +483,16 @@ func (cm *CitationMap) GetCitationNumbers(url string) []int {
 
 // Handler serves the interface page and processes scans.
 func Handler(w http.ResponseWriter, r *http.Request) {
-    t, err := template.ParseFS(tmplFS, "templates/index.html")
-    if err != nil {
-        http.Error(w, "template error", http.StatusInternalServerError)
-        return
+    lang := requestLang(r)
+    data := pageData{Title: "IABot-Go", Lang: lang, T: translations(lang)}
+    data.JS = jsMessages(data.T)
+    data.Message = data.T["intro"]
+    if r.URL.Query().Get("lang") != "" {
+        data.LangParam = lang
     }
-
-    data := pageData{Title: "IABot-Go", Message: "Enter an English Wikipedia page to scan external links."}
+    status := http.StatusOK
+    var scannedAt time.Time // Set when a scan succeeded; enables HTTP caching
+    refresh := refreshRequested(r.URL.Query())
 
     if r.Method == http.MethodGet {
         q := strings.TrimSpace(r.URL.Query().Get("page"))
@@ -247,206 +501,435 @@ func Handler(w http.ResponseWriter, r *http.Request) {
             viewMode = "url" // Default to URL view
         }
         data.ViewMode = viewMode
+        data.ArchiveOnly = archiveOnlyRequested(r)
+        data.AddedSince = strings.TrimSpace(r.URL.Query().Get("added_since"))
+        data.OldID = strings.TrimSpace(r.URL.Query().Get("oldid"))
+        data.ArchiveResolved = currentConfig().ArchiveResolvedURL
 
         if q != "" {
             data.Query = q
-            results, citationMap, err := scanPage(r.Context(), q)
+            sep, ext, isExport := exportSeparator(r.URL.Query().Get("format"))
+            title, err := validatePageTitle(q)
             if err != nil {
-                data.Error = err.Error()
+                if isExport {
+                    http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+                    return
+                }
+                data.Error = "Invalid page title: " + err.Error()
+                status = http.StatusBadRequest
+            } else if filter, ferr := parseResultFilter(r.URL.Query(), htmlPageSize); ferr != nil && !isExport {
+                data.Error = ferr.Error()
+                status = http.StatusBadRequest
+            } else if since, serr := parseAddedSince(r.URL.Query()); serr != nil {
+                if isExport {
+                    http.Error(w, serr.Error(), http.StatusBadRequest)
+                    return
+                }
+                data.Error = serr.Error()
+                status = http.StatusBadRequest
+            } else if oldid, oerr := parseOldID(r.URL.Query()); oerr != nil {
+                if isExport {
+                    http.Error(w, oerr.Error(), http.StatusBadRequest)
+                    return
+                }
+                data.Error = oerr.Error()
+                status = http.StatusBadRequest
             } else {
-                data.Results = results
-                if citationMap != nil {
-                    data.Citations = citationMap.Citations
+                // Reloads and paging reuse a recent scan unless ?refresh=1
+                opts := scanOptions{archiveOnly: data.ArchiveOnly, addedSince: since, oldid: oldid}
+                scan, hit, err := cachedScanPage(r.Context(), title, opts, !refresh)
+                results, citationMap := scan.results, scan.citationMap
+
+                // Tabular export skips the HTML page entirely
+                if isExport {
+                    if err != nil {
+                        http.Error(w, err.Error(), http.StatusBadGateway)
+                        return
+                    }
+                    writeResultsCSV(w, title, results, sep, ext)
+                    return
+                }
+
+                // A timed-out scan still shows the links it got to
+                partial, isPartial := asPartialScan(err)
+                if err != nil && !isPartial {
+                    data.Error = err.Error()
+                    if isPageMissing(err) {
+                        status = http.StatusNotFound
+                    } else if isWrongRevision(err) {
+                        status = http.StatusBadRequest
+                    }
+                } else {
+                    data.Summary = summarizeResults(results, citationMap)
+                    base := url.Values{"page": {q}, "view": {viewMode}}
+                    if data.ArchiveOnly {
+                        base.Set("archive_only", "1")
+                    }
+                    if data.LangParam != "" {
+                        base.Set("lang", data.LangParam)
+                    }
+                    if data.AddedSince != "" {
+                        base.Set("added_since", data.AddedSince)
+                    }
+                    if data.OldID != "" {
+                        base.Set("oldid", data.OldID)
+                    }
+                    data.Revision = scannedRevision(citationMap)
+                    data.ScannedTitle, data.RedirectedFrom = scannedTitle(citationMap, title)
+                    page, matched := filter.apply(results)
+                    data.Results = page
+                    data.Pager = newPager(results, filter, matched, base)
+                    data.ViewURLs = map[string]template.URL{"url": withParam(base, "view", "url"), "citation": withParam(base, "view", "citation")}
+                    data.ExportURLs = map[string]template.URL{"csv": withParam(base, "format", "csv"), "tsv": withParam(base, "format", "tsv")}
+                    if hit {
+                        data.CachedAge = int(time.Since(scan.at).Minutes())
+                        data.RefreshURL = refreshURL(base)
+                    }
+                    if isPartial {
+                        data.Checked, data.Unchecked = partial.checked, partial.total-partial.checked
+                    } else {
+                        scannedAt = scan.at
+                    }
+                    if citationMap != nil {
+                        data.Citations = citationMap.Citations
+                    }
                 }
             }
         }
     }
 
-    _ = t.Execute(w, data)
+    // Render to a buffer first so a template failure can still become a 500
+    var buf bytes.Buffer
+    if err := indexTmpl.Execute(&buf, data); err != nil {
+        logFor(r.Context(), "http").Error("template execution failed", "error", err)
+        http.Error(w, "template error", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    if refresh {
+        // Don't let a proxy hold on to a forced rescan
+        w.Header().Set("Cache-Control", "no-store")
+    } else if status == http.StatusOK && !scannedAt.IsZero() && writeCacheHeaders(w, r, data.Lang, scannedAt) {
+        return
+    }
+    w.WriteHeader(status)
+    buf.WriteTo(w)
 }
 
 func scanPage(ctx context.Context, title string) ([]linkResult, *CitationMap, error) {
-    log.Printf("[SCAN] Starting scan for page: %s", title)
+    return scanPageWith(ctx, title, scanOptions{})
+}
+
+// scanPageWith is scanPage with options, e.g. a progress callback for
+// streaming or an archive-only scan. Concurrent identical scans without a
+// progress callback share one execution (see coalescedScan).
+func scanPageWith(ctx context.Context, title string, opts scanOptions) ([]linkResult, *CitationMap, error) {
+    if opts.onResult != nil {
+        return runScan(ctx, title, opts)
+    }
+    return coalescedScan(ctx, opts.key(title), func(ctx context.Context) ([]linkResult, *CitationMap, error) {
+        return runScan(ctx, title, opts)
+    })
+}
+
+// runScan fetches and parses the page, then checks its links
+func runScan(ctx context.Context, title string, opts scanOptions) (results []linkResult, citationMap *CitationMap, err error) {
+    ctx = withLogAttrs(withScanID(ctx), "page", title)
+    logger := logFor(ctx, "scan")
+    logger.Info("starting scan")
+    defer func() {
+        outcome := "ok"
+        if _, partial := asPartialScan(err); partial {
+            outcome = "partial"
+        } else if err != nil {
+            outcome = "error"
+        }
+        scansTotal.WithLabelValues(outcome).Inc()
+    }()
+
+    cfg := currentConfig()
+
+    // Scan budget covers all link checks (5 minutes by default); each check
+    // gets its own timeout within it, see withCheckTimeout
+    ctx, cancel := context.WithTimeout(ctx, cfg.ScanTimeout)
+    defer cancel()
 
     // Fetch wikitext via MediaWiki API to parse citations
-    api := "https://en.wikipedia.org/w/api.php"
     v := url.Values{}
     v.Set("action", "parse")
-    v.Set("page", title)
+    if opts.oldid != 0 {
+        // parse takes either a page or a revision, not both
+        v.Set("oldid", strconv.FormatInt(opts.oldid, 10))
+    } else {
+        v.Set("page", title)
+        // Scan the article a redirect points to, not the one-line redirect
+        // page itself
+        v.Set("redirects", "1")
+    }
     v.Set("prop", "wikitext")
-    v.Set("format", "json")
-    // set origin to please CORS and some edge policies; harmless for server-side
-    v.Set("origin", "*")
-    reqURL := api + "?" + v.Encode()
-
-    // Increase timeout to 5 minutes to handle all link checks
-    ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-    defer cancel()
+    if cfg.FetchTemplates {
+        v.Set("prop", "wikitext|templates")
+    }
 
-    log.Printf("[SCAN] Fetching wikitext from MediaWiki API...")
-    req, _ := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-    req.Header.Set("User-Agent", "IABot-Go/0.1 (+https://github.com/comaeclipse/IABot-Go)")
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        log.Printf("[SCAN] Error fetching from MediaWiki API: %v", err)
-        return nil, nil, err
+    logger.Debug("fetching wikitext from MediaWiki API")
+    parsed, err := fetchParse(ctx, v)
+    if err == nil && opts.oldid != 0 {
+        err = checkRevisionTitle(parsed.Title, title, opts.oldid)
     }
-    defer resp.Body.Close()
-    body, _ := io.ReadAll(resp.Body)
-    log.Printf("[SCAN] MediaWiki API response status: %d", resp.StatusCode)
-
-    // JSON decode for wikitext
-    var parsed struct {
-        Parse struct {
-            Wikitext struct {
-                Content string `json:"*"`
-            } `json:"wikitext"`
-        } `json:"parse"`
-        Error any `json:"error"`
-    }
-    if err := json.Unmarshal(body, &parsed); err != nil {
-        // include a snippet of the payload to aid debugging (common case: missing UA -> HTML/plaintext)
-        snippet := string(body)
-        if len(snippet) > 240 { snippet = snippet[:240] + "..." }
-        log.Printf("[SCAN] Error decoding MediaWiki response: %v", err)
-        return nil, nil, &apiError{msg: "mediawiki api decode", status: resp.StatusCode, payload: snippet}
+    if err != nil {
+        logger.Error("fetching from MediaWiki API failed", "error", err)
+        return nil, nil, revisionError(err, title, opts.oldid)
     }
 
     // Parse citations from wikitext
-    wikitext := parsed.Parse.Wikitext.Content
-    log.Printf("[SCAN] Got wikitext (%d chars), parsing citations...", len(wikitext))
-    citationMap := ParseCitations(wikitext)
-    log.Printf("[SCAN] Found %d citations with URLs, %d unique URLs", len(citationMap.Citations), len(citationMap.URLToCitation))
-
-    // Get unique URLs from citation map
-    out := citationMap.GetUniqueURLs()
-    sort.Strings(out)
-    if len(out) > 50 {
-        log.Printf("[SCAN] Limiting to first 50 of %d unique links", len(out))
-        out = out[:50]
-    } else {
-        log.Printf("[SCAN] Processing %d unique links", len(out))
+    wikitext := parsed.Wikitext.Content
+    logger.Info("got wikitext, parsing citations", "chars", len(wikitext), "revision", parsed.RevID)
+    citationMap = ParseCitations(wikitext)
+    citationMap.Revision = parsed.RevID
+    citationMap.Title = parsed.Title
+    if r := parsed.Redirects; len(r) > 0 {
+        citationMap.RedirectedFrom = r[0].From
+        logger.Info("followed redirect", "from", r[0].From, "to", parsed.Title)
     }
-
-    results := make([]linkResult, 0, len(out))
-    for i, u := range out {
-        // Check if context is cancelled
-        select {
-        case <-ctx.Done():
-            log.Printf("[SCAN] Context cancelled after processing %d/%d links: %v", i, len(out), ctx.Err())
-            return results, citationMap, fmt.Errorf("scan cancelled after %d links: %w", i, ctx.Err())
-        default:
+    logger.Info("parsed citations", "citations", len(citationMap.Citations), "unique_urls", len(citationMap.URLToCitation))
+    if cfg.FetchTemplates {
+        names := make([]string, len(parsed.Templates))
+        for i, t := range parsed.Templates {
+            names[i] = t.Name
         }
+        citationMap.Templates = citationTemplateUsage(names, wikitext)
+    }
 
-        log.Printf("[SCAN] [%d/%d] Checking: %s", i+1, len(out), u)
-        lr := linkResult{
-            URL:             u,
-            CitationNumbers: citationMap.GetCitationNumbers(u),
+    // Get unique URLs from citation map, collapsing equivalent spellings.
+    // With added_since only links missing from the baseline revision count.
+    urls := citationMap.GetUniqueURLs()
+    if opts.addedSince != "" {
+        // The baseline lookup is a query, which doesn't follow redirects the
+        // way parse did, so it needs the title parse actually read
+        canonical := title
+        if citationMap.Title != "" {
+            canonical = citationMap.Title
         }
-
-        // Skip live/archive checks for URLs that are already archives
-        if isArchiveURL(u) {
-            lr.LiveCode = 0
-            lr.LiveStatus = "archive URL (skipped)"
-            lr.Archived = true
-            lr.ArchiveURL = u
-            lr.ArchiveStatus = "is archive"
-            log.Printf("[SCAN] [%d/%d] Detected as archive URL, skipping checks", i+1, len(out))
-            results = append(results, lr)
-            continue
+        revid, err := baselineRevision(ctx, canonical, opts.addedSince)
+        if err != nil {
+            return nil, citationMap, err
         }
+        baseline, err := baselineURLs(ctx, canonical, revid)
+        if err != nil {
+            return nil, citationMap, err
+        }
+        urls = filterAdded(urls, baseline)
+        logger.Info("comparing with baseline revision", "since", opts.addedSince, "baseline_revision", revid, "added_urls", len(urls))
+    }
+    out, citationNumbers := prepareURLs(ctx, urls, citationMap, cfg)
 
-        code, status := checkLive(ctx, u)
-        lr.LiveCode = code
-        lr.LiveStatus = status
-        log.Printf("[SCAN] [%d/%d] Live check: %d %s", i+1, len(out), code, status)
-
-        arch, aurl, astatus := checkWayback(ctx, u)
-        lr.Archived = arch
-        lr.ArchiveURL = aurl
-        lr.ArchiveStatus = astatus
-        log.Printf("[SCAN] [%d/%d] Wayback check: archived=%v status=%s", i+1, len(out), arch, astatus)
-
-        results = append(results, lr)
+    opts.decorate = func(ctx context.Context, lr *linkResult) {
+        lr.CitationContext = citationMap.ContextFor(lr.CitationNumbers)
+        lr.CitedArchiveURL = citationMap.CitedArchive(lr.URL, lr.CitationNumbers)
+        lr.Complete = linkComplete(ctx, *lr, cfg)
+    }
+    results, err = checkLinks(ctx, out, citationNumbers, opts)
+    markCompleteCitations(citationMap, results, cfg)
+    if err != nil {
+        return results, citationMap, err
+    }
+    logger.Info("completed scan", "links", len(results))
+    if !opts.archiveOnly && opts.addedSince == "" && opts.oldid == 0 {
+        scanHistory.record(title, time.Now(), results)
     }
-    log.Printf("[SCAN] Completed scan: processed %d links", len(results))
     return results, citationMap, nil
 }
 
-func checkLive(ctx context.Context, raw string) (int, string) {
-    // Try HEAD then fallback to GET if HEAD returns 405 or fails
-    status := "unknown"
-    code := 0
-    client := &http.Client{
-        Timeout: 8 * time.Second,
-        CheckRedirect: func(req *http.Request, via []*http.Request) error {
-            // Allow up to 10 redirects (default)
-            if len(via) >= 10 {
-                return http.ErrUseLastResponse
+// liveResult is the outcome of a live check
+type liveResult struct {
+    Code          int
+    Status        string
+    Category      string   // One of the liveCategory* codes
+    RedirectChain []string // "<status> <url>" per hop, ending with the final response (empty if not redirected)
+    FinalURL      string   // URL of the final response after redirects
+    TLS           *tlsInfo // Certificate of the final response, or the one rejected on failure (HTTPS only)
+    ContentType   string   // Media type of the final response, e.g. "application/pdf"
+    ContentLength int64    // Size of the resource in bytes, 0 if not stated
+}
+
+func checkLive(ctx context.Context, raw string) liveResult {
+    // Try HEAD then fall back to GET if HEAD returns one of
+    // Config.GETFallbackStatuses (403, 405 or 501 by default)
+    lr := liveResult{Status: "unknown", Category: liveCategoryNetworkError}
+    start := time.Now()
+    defer func() { liveCheckDuration.Observe(time.Since(start).Seconds()) }()
+    cfg := currentConfig()
+    logger := logFor(ctx, "live").With("url", raw)
+    ctx, done := withCheckTimeout(withDestinationGuard(ctx), cfg.LiveTimeout, logger)
+    defer done()
+
+    host := ""
+    if u, err := url.Parse(raw); err == nil {
+        host = strings.ToLower(u.Host)
+        if err := checkDestination(ctx, u, cfg); err != nil {
+            lr.Status = classifyError(err)
+            if !destinationBlocked(err) {
+                // Unresolvable: reported like a failed request
+                logger.Warn("resolving host failed", "error", err)
+                return lr
             }
-            return nil
-        },
+            logger.Warn("refusing to check", "reason", err)
+            lr.Category = liveCategorySkipped
+            return lr
+        }
     }
 
-    // HEAD
-    req, err := http.NewRequestWithContext(ctx, http.MethodHead, raw, nil)
-    if err != nil {
-        log.Printf("[LIVE] Error creating HEAD request for %s: %v", raw, err)
-        return code, classifyError(err)
+    if cfg.RespectRobots {
+        allowed, delay := robotsAllowed(ctx, raw, cfg)
+        if !allowed {
+            logger.Info("skipping: disallowed by robots.txt")
+            lr.Status = "skipped: robots disallow"
+            lr.Category = liveCategorySkipped
+            return lr
+        }
+        if host != "" {
+            if err := liveHostLimiter.wait(ctx, host, delay); err != nil {
+                lr.Status = classifyError(err)
+                return lr
+            }
+        }
     }
 
-    resp, err := client.Do(req)
-    if err != nil {
-        log.Printf("[LIVE] HEAD request failed for %s: %v", raw, err)
-        return code, classifyError(err)
-    } else {
-        code = resp.StatusCode
-        status = classifyStatus(code, resp.Status)
-        resp.Body.Close()
-        log.Printf("[LIVE] HEAD response for %s: %d %s", raw, code, status)
-        if code != http.StatusMethodNotAllowed && code != http.StatusNotImplemented {
-            return code, status
+    // Hosts that already rejected HEAD this process go straight to GET
+    headRejected := getOnlyHosts.has(host)
+    if !headRejected {
+        // HEAD
+        headCtx, trace := withRedirectTrace(ctx)
+        headCtx, cancelHead := withResponseTimeout(headCtx, cfg.ResponseTimeout)
+        defer cancelHead()
+        req, err := http.NewRequestWithContext(headCtx, http.MethodHead, raw, nil)
+        if err != nil {
+            logger.Error("creating HEAD request failed", "error", err)
+            lr.Status = classifyError(err)
+            return lr
+        }
+
+        resp, err := httpClient.Do(req)
+        if err != nil {
+            err = responseTimeoutCause(headCtx, err)
+            logger.Warn("HEAD request failed", "error", err, "duration", time.Since(start))
+            lr.Status = classifyError(err)
+            lr.TLS = tlsFromError(err)
+            return lr
+        } else {
+            lr.Code = resp.StatusCode
+            lr.Status, lr.Category = classifyStatus(lr.Code, resp.Status)
+            lr.RedirectChain, lr.FinalURL = trace.finish(resp)
+            if trace.capped {
+                lr.Status, lr.Category = statusTooManyRedirects, liveCategoryTooManyRedirects
+            }
+            lr.TLS = tlsFromState(resp.TLS)
+            lr.ContentType, lr.ContentLength = mediaType(resp.Header.Get("Content-Type")), responseLength(resp)
+            resp.Body.Close()
+            logger.Debug("HEAD response", "status_code", lr.Code, "status", lr.Status, "duration", time.Since(start))
+            if !containsInt(cfg.GETFallbackStatuses, lr.Code) {
+                return lr
+            }
+            logger.Debug("HEAD rejected, trying GET", "status_code", lr.Code)
+            headRejected = true
         }
-        log.Printf("[LIVE] HEAD returned %d, trying GET for %s", code, raw)
     }
 
     // GET with small range
-    req2, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+    getCtx, trace := withRedirectTrace(ctx)
+    getCtx, cancelGet := withResponseTimeout(getCtx, cfg.ResponseTimeout)
+    defer cancelGet()
+    req2, err := http.NewRequestWithContext(getCtx, http.MethodGet, raw, nil)
     if err != nil {
-        log.Printf("[LIVE] Error creating GET request for %s: %v", raw, err)
-        return code, classifyError(err)
+        logger.Error("creating GET request failed", "error", err)
+        lr.Status = classifyError(err)
+        return lr
     }
     req2.Header.Set("Range", "bytes=0-0")
-    resp2, err := client.Do(req2)
+    resp2, err := httpClient.Do(req2)
     if err != nil {
-        log.Printf("[LIVE] GET request failed for %s: %v", raw, err)
-        return code, classifyError(err)
+        err = responseTimeoutCause(getCtx, err)
+        logger.Warn("GET request failed", "error", err, "duration", time.Since(start))
+        lr.Status = classifyError(err)
+        lr.TLS = tlsFromError(err)
+        return lr
+    }
+    lr.Code = resp2.StatusCode
+    lr.Status, lr.Category = classifyStatus(lr.Code, resp2.Status)
+    lr.RedirectChain, lr.FinalURL = trace.finish(resp2)
+    if trace.capped {
+        lr.Status, lr.Category = statusTooManyRedirects, liveCategoryTooManyRedirects
     }
-    code = resp2.StatusCode
-    status = classifyStatus(code, resp2.Status)
+    lr.TLS = tlsFromState(resp2.TLS)
+    lr.ContentType, lr.ContentLength = mediaType(resp2.Header.Get("Content-Type")), responseLength(resp2)
     io.Copy(io.Discard, resp2.Body)
     resp2.Body.Close()
-    log.Printf("[LIVE] GET response for %s: %d %s", raw, code, status)
-    return code, status
+    logger.Debug("GET response", "status_code", lr.Code, "status", lr.Status, "duration", time.Since(start))
+    // Remember hosts where GET succeeds after HEAD was rejected
+    if headRejected && lr.Code < 400 {
+        getOnlyHosts.add(host)
+    }
+    return lr
+}
+
+// containsInt reports whether v is in list
+func containsInt(list []int, v int) bool {
+    for _, x := range list {
+        if x == v {
+            return true
+        }
+    }
+    return false
+}
+
+// isOffDomain reports whether a redirect landed on a different host than the
+// original URL. A leading "www." is ignored on both sides.
+func isOffDomain(original, final string) bool {
+    if final == "" {
+        return false
+    }
+    o, err1 := url.Parse(original)
+    f, err2 := url.Parse(final)
+    if err1 != nil || err2 != nil {
+        return false
+    }
+    oh := strings.TrimPrefix(strings.ToLower(o.Hostname()), "www.")
+    fh := strings.TrimPrefix(strings.ToLower(f.Hostname()), "www.")
+    return oh != fh
 }
 
-// classifyStatus provides a human-readable interpretation of HTTP status codes
-func classifyStatus(code int, original string) string {
+// Live categories are the stable, machine-readable counterpart of the
+// human-readable live status (linkResult.LiveCategory)
+const (
+    liveCategorySuccess          = "success"            // 2xx
+    liveCategoryRedirect         = "redirect"           // 3xx that wasn't followed (no Location, or redirects disabled)
+    liveCategoryForbidden        = "forbidden"          // 403
+    liveCategoryRateLimited      = "rate-limited"       // 429
+    liveCategoryClientError      = "client-error"       // Other 4xx
+    liveCategoryServerError      = "server-error"       // 5xx
+    liveCategoryNetworkError     = "network-error"      // No HTTP response (DNS, timeout, TLS, ...)
+    liveCategoryTooManyRedirects = "too-many-redirects" // Gave up at Config.MaxRedirects, usually a loop
+    liveCategorySkipped          = "skipped"            // Not checked (archive URL, archive-only, robots, blocked destination)
+)
+
+// classifyStatus provides a human-readable interpretation of HTTP status
+// codes, plus its stable live category
+func classifyStatus(code int, original string) (status, category string) {
     switch {
     case code >= 200 && code < 300:
-        return "OK"  // 2xx = success
+        return "OK", liveCategorySuccess  // 2xx = success
     case code >= 300 && code < 400:
-        return original  // 3xx = redirect (followed automatically)
+        return original, liveCategoryRedirect  // 3xx = redirect (followed automatically)
     case code == 403:
-        return "403 Forbidden"  // May be alive but blocked
+        return "403 Forbidden", liveCategoryForbidden  // May be alive but blocked
     case code == 429:
-        return "429 Rate Limited"  // Alive but throttled
+        return "429 Rate Limited", liveCategoryRateLimited  // Alive but throttled
     case code >= 400 && code < 500:
-        return original  // 4xx = client error (likely dead)
+        return original, liveCategoryClientError  // 4xx = client error (likely dead)
     case code >= 500:
-        return original  // 5xx = server error (dead/temporary)
+        return original, liveCategoryServerError  // 5xx = server error (dead/temporary)
     default:
-        return original
+        return original, liveCategoryNetworkError
     }
 }
 
@@ -455,11 +938,20 @@ func classifyError(err error) string {
     if err == nil {
         return "unknown"
     }
+    // Destinations refused by checkDestination or guardedDial
+    for _, blocked := range []error{errPrivateAddress, errPortNotAllowed} {
+        if errors.Is(err, blocked) {
+            return blocked.Error()
+        }
+    }
     errStr := err.Error()
     switch {
     case strings.Contains(errStr, "no such host"), strings.Contains(errStr, "DNS"):
         return "DNS lookup failed"
     case strings.Contains(errStr, "certificate"), strings.Contains(errStr, "tls"), strings.Contains(errStr, "TLS"):
+        if problem := certProblem(err); problem != "" {
+            return "TLS error: " + problem
+        }
         return "TLS/certificate error"
     case strings.Contains(errStr, "timeout"), strings.Contains(errStr, "deadline exceeded"):
         return "timeout"
@@ -472,74 +964,148 @@ func classifyError(err error) string {
     }
 }
 
-// isArchiveURL detects if a URL is already an archive URL
+// archiveHosts are hosts whose URLs are archive captures. A URL matches when
+// its host equals an entry or is a subdomain of it.
+var archiveHosts = []string{
+    "web.archive.org",           // Internet Archive Wayback Machine
+    "wayback.archive.org",       // Legacy Wayback hostname
+    "archive.today",             // archive.today family
+    "archive.is",
+    "archive.ph",
+    "archive.fo",
+    "archive.li",
+    "archive.md",
+    "archive.vn",
+    "webcitation.org",           // WebCite
+    "perma.cc",                  // Perma.cc
+    "archive-it.org",            // Archive-It
+    "webarchive.org.uk",         // UK Web Archive
+    "webarchive.nationalarchives.gov.uk", // UK National Archives
+    "arquivo.pt",                // Portuguese Web Archive
+    "webarchive.library.unt.edu", // UNT Web Archive
+    "webarchive.loc.gov",        // Library of Congress
+    "swap.stanford.edu",         // Stanford Web Archive Portal
+    "vefsafn.is",                // Icelandic Web Archive
+    "screenshots.com",           // Screenshots archive
+    "ghostarchive.org",          // Ghost Archive
+    "cachedview.nl",             // CachedView
+    "timetravel.mementoweb.org", // Memento Time Travel
+    "webcache.googleusercontent.com", // Google Cache
+    "cc.bingj.com",              // Bing Cache
+}
+
+// archivePathPrefixes are archives that live under a path of a general host
+var archivePathPrefixes = map[string]string{
+    "archive.org": "/web/", // Alternative Wayback path
+}
+
+// isArchiveURL detects if a URL is already an archive URL. Only the parsed
+// host (and path, for archivePathPrefixes) is compared, so a mention of an
+// archive elsewhere in the URL (e.g. "?ref=archive.org") doesn't count.
 func isArchiveURL(rawURL string) bool {
-    lower := strings.ToLower(rawURL)
-    archiveHosts := []string{
-        "web.archive.org",           // Internet Archive Wayback Machine
-        "archive.org/web/",          // Alternative Wayback path
-        "archive.today",             // archive.today family
-        "archive.is",
-        "archive.ph",
-        "archive.fo",
-        "archive.li",
-        "archive.md",
-        "archive.vn",
-        "webcitation.org",           // WebCite
-        "perma.cc",                  // Perma.cc
-        "archive-it.org",            // Archive-It
-        "webarchive.org.uk",         // UK Web Archive
-        "webarchive.nationalarchives.gov.uk", // UK National Archives
-        "arquivo.pt",                // Portuguese Web Archive
-        "webarchive.library.unt.edu", // UNT Web Archive
-        "webarchive.loc.gov",        // Library of Congress
-        "swap.stanford.edu",         // Stanford Web Archive Portal
-        "vefsafn.is",                // Icelandic Web Archive
-        "screenshots.com",           // Screenshots archive
-    }
-
-    for _, host := range archiveHosts {
-        if strings.Contains(lower, host) {
+    u, err := url.Parse(strings.TrimSpace(rawURL))
+    if err != nil {
+        return false
+    }
+    host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+    if host == "" {
+        return false
+    }
+
+    for _, h := range archiveHosts {
+        if hostMatches(host, h) {
+            return true
+        }
+    }
+    for h, prefix := range archivePathPrefixes {
+        if hostMatches(host, h) && strings.HasPrefix(u.Path, prefix) {
             return true
         }
     }
     return false
 }
 
-func checkWayback(ctx context.Context, raw string) (bool, string, string) {
+// hostMatches reports whether host is domain or one of its subdomains
+func hostMatches(host, domain string) bool {
+    return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// waybackResult is the outcome of an archive lookup across all providers
+type waybackResult struct {
+    Archived  bool
+    URL       string
+    Status    string    // Snapshot HTTP status when archived, otherwise why not
+    Timestamp time.Time // Snapshot capture time (zero if not archived)
+    SnapshotStatus int  // Captured HTTP status of the closest snapshot, even a rejected one (0 if unknown)
+    Source    string    // Provider name ("wayback", ...), or the archive host of a Memento fallback
+}
+
+// WaybackProvider looks up snapshots with the Internet Archive's
+// availability API. It is the default ArchiveProvider.
+type WaybackProvider struct{}
+
+// Name implements ArchiveProvider
+func (WaybackProvider) Name() string { return "wayback" }
+
+// Lookup implements ArchiveProvider. Only snapshots with one of
+// Config.AcceptedSnapshotStatuses (200/203/206 by default) and a plausible
+// timestamp count as found.
+func (p WaybackProvider) Lookup(ctx context.Context, raw string) (ArchiveSnapshot, error) {
+    return p.LookupNear(ctx, raw, "")
+}
+
+// LookupNear is Lookup for the snapshot closest to timestamp (YYYYMMDD, or
+// any prefix of YYYYMMDDhhmmss down to YYYY) rather than to now, e.g. the
+// access-date of a citation. An empty timestamp means now.
+func (WaybackProvider) LookupNear(ctx context.Context, raw, timestamp string) (ArchiveSnapshot, error) {
+    if err := validateWaybackTimestamp(timestamp); err != nil {
+        return ArchiveSnapshot{}, err
+    }
+
     // Wayback "available" v2 API
     v := url.Values{}
     v.Set("url", raw)
+    if timestamp != "" {
+        v.Set("timestamp", timestamp)
+    }
     // TODO: Investigate correct format for statuscodes parameter - comma-separated breaks API
     // The official IABot uses this, but our tests show it returns empty results
     // v.Set("statuscodes", "200,203,206")
     reqURL := "https://archive.org/wayback/available?" + v.Encode()
-    ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
-    defer cancel()
+    cfg := currentConfig()
+    logger := logFor(ctx, "wayback").With("url", raw)
+    ctx, done := withCheckTimeout(ctx, cfg.WaybackTimeout, logger)
+    defer done()
+
+    outcome := "error"
+    defer func() { waybackLookupsTotal.WithLabelValues(outcome).Inc() }()
 
-    log.Printf("[WAYBACK] Checking %s", raw)
+    logger.Debug("checking availability", "near", timestamp)
     req, _ := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-    req.Header.Set("User-Agent", "IABot-Go/0.1 (+https://github.com/comaeclipse/IABot-Go)")
-    resp, err := http.DefaultClient.Do(req)
+    req.Header.Set("User-Agent", cfg.UserAgent)
+    resp, err := httpClient.Do(req)
     if err != nil {
-        log.Printf("[WAYBACK] Request failed for %s: %v", raw, err)
-        return false, "", "error: " + err.Error()
+        logger.Warn("request failed", "error", err)
+        return ArchiveSnapshot{}, err
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        log.Printf("[WAYBACK] Non-OK status for %s: %d %s", raw, resp.StatusCode, resp.Status)
-        return false, "", "HTTP " + resp.Status
+        logger.Warn("non-OK status", "status_code", resp.StatusCode, "status", resp.Status)
+        return ArchiveSnapshot{Status: "HTTP " + resp.Status}, nil
     }
 
-    b, err := io.ReadAll(resp.Body)
+    b, err := readBody(resp.Body, cfg.MaxResponseBytes)
     if err != nil {
-        log.Printf("[WAYBACK] Read error for %s: %v", raw, err)
-        return false, "", "read error"
+        logger.Warn("read error", "error", err)
+        if errors.Is(err, errResponseTooLarge) {
+            return ArchiveSnapshot{Status: "response too large"}, nil
+        }
+        return ArchiveSnapshot{Status: "read error"}, nil
     }
 
     // Log the raw response for debugging
-    log.Printf("[WAYBACK] Raw API response for %s: %s", raw, string(b))
+    logger.Debug("raw API response", "body", string(b))
 
     var wb struct {
         ArchivedSnapshots struct {
@@ -552,57 +1118,147 @@ func checkWayback(ctx context.Context, raw string) (bool, string, string) {
         } `json:"archived_snapshots"`
     }
     if err := json.Unmarshal(b, &wb); err != nil {
-        log.Printf("[WAYBACK] JSON decode error for %s: %v", raw, err)
-        return false, "", "decode error: " + err.Error()
+        logger.Warn("JSON decode error", "error", err)
+        return ArchiveSnapshot{Status: "decode error: " + err.Error()}, nil
     }
 
     c := wb.ArchivedSnapshots.Closest
-    log.Printf("[WAYBACK] Parsed response for %s: Available=%v, URL=%s, Status=%s, Timestamp=%s", raw, c.Available, c.URL, c.Status, c.Timestamp)
+    logger.Debug("parsed response", "available", c.Available, "archive_url", c.URL, "snapshot_status", c.Status, "timestamp", c.Timestamp)
 
     if c.Available && c.URL != "" {
         // Validate timestamp (format: YYYYMMDDHHmmss)
-        if !isValidArchiveTimestamp(c.Timestamp) {
-            log.Printf("[WAYBACK] Invalid timestamp for %s: %s (rejected)", raw, c.Timestamp)
-            return false, "", "invalid archive timestamp"
+        ts, ok := parseArchiveTimestamp(c.Timestamp)
+        if !ok {
+            logger.Info("rejected: invalid timestamp", "timestamp", c.Timestamp)
+            outcome = "rejected"
+            return ArchiveSnapshot{Status: "invalid archive timestamp"}, nil
         }
-        // Filter by status code - only accept good snapshots (200, 203, 206)
+        // Some valid captures come back with status "" or "-". Unless
+        // Config.UnknownSnapshotStatus says to reject them, ask CDX for the
+        // real status, and accept them if nobody knows it.
+        status := c.Status
+        code, known := snapshotStatusCode(status)
+        if !known && cfg.UnknownSnapshotStatus != UnknownSnapshotStatusReject {
+            // With every status accepted the lookup wouldn't change anything
+            if cfg.UnknownSnapshotStatus != UnknownSnapshotStatusAccept && len(cfg.AcceptedSnapshotStatuses) > 0 {
+                cdxCode, ok, err := cdxSnapshotStatus(ctx, raw, c.Timestamp)
+                if err != nil {
+                    logger.Warn("cdx status lookup failed", "error", err)
+                } else if ok {
+                    logger.Debug("snapshot status from cdx", "snapshot_status", cdxCode)
+                    code, known, status = cdxCode, true, strconv.Itoa(cdxCode)
+                }
+            }
+            if !known {
+                archiveURL := waybackFlavorURL(c.URL, cfg.ArchiveURLFlavor)
+                logger.Info("found archive with unknown status", "archive_url", archiveURL, "snapshot_status", c.Status)
+                outcome = "archived"
+                return ArchiveSnapshot{Found: true, URL: archiveURL, Status: snapshotStatusUnknown, Timestamp: ts}, nil
+            }
+        }
+        // Filter by status code - only accept good snapshots (200, 203, 206
+        // unless configured otherwise; an empty list accepts any).
         // Do this server-side since the API parameter doesn't work as expected
-        if c.Status != "200" && c.Status != "203" && c.Status != "206" {
-            log.Printf("[WAYBACK] Bad snapshot status for %s: %s (rejected, only accepting 200/203/206)", raw, c.Status)
-            return false, "", fmt.Sprintf("snapshot has bad status: %s", c.Status)
+        if len(cfg.AcceptedSnapshotStatuses) > 0 && !containsInt(cfg.AcceptedSnapshotStatuses, code) {
+            logger.Info("rejected: bad snapshot status", "snapshot_status", status, "accepted", cfg.AcceptedSnapshotStatuses)
+            outcome = "rejected"
+            return ArchiveSnapshot{Status: fmt.Sprintf("snapshot has bad status: %s", status), SnapshotStatus: code}, nil
         }
-        log.Printf("[WAYBACK] Found archive for %s: %s (status: %s)", raw, c.URL, c.Status)
-        return true, c.URL, c.Status
+        archiveURL := waybackFlavorURL(c.URL, cfg.ArchiveURLFlavor)
+        logger.Info("found archive", "archive_url", archiveURL, "snapshot_status", status)
+        outcome = "archived"
+        return ArchiveSnapshot{Found: true, URL: archiveURL, Status: status, SnapshotStatus: code, Timestamp: ts}, nil
     }
-    log.Printf("[WAYBACK] No archive found for %s (Available=%v, URL empty=%v)", raw, c.Available, c.URL == "")
-    return false, "", "not archived"
+    logger.Info("no archive found", "available", c.Available, "url_empty", c.URL == "")
+    outcome = "not_archived"
+    return ArchiveSnapshot{Status: "not archived"}, nil
 }
 
-// isValidArchiveTimestamp validates Wayback Machine timestamps (format: YYYYMMDDHHmmss)
+// validateWaybackTimestamp checks a timestamp to send to the availability
+// API: empty, or a YYYYMMDDhhmmss prefix of at least the year that is a
+// real date no earlier than the Wayback Machine itself
+func validateWaybackTimestamp(timestamp string) error {
+    if timestamp == "" {
+        return nil
+    }
+    const layout = "20060102150405"
+    if n := len(timestamp); n < 4 || n > len(layout) || n%2 != 0 {
+        return fmt.Errorf("invalid timestamp %q: want YYYYMMDD (or a prefix of YYYYMMDDhhmmss)", timestamp)
+    }
+    t, err := time.Parse(layout[:len(timestamp)], timestamp)
+    if err != nil {
+        return fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+    }
+    if t.Year() < 1996 {
+        return fmt.Errorf("invalid timestamp %q: before the Wayback Machine started", timestamp)
+    }
+    return nil
+}
+
+// parseArchiveTimestamp parses and validates Wayback Machine timestamps (format: YYYYMMDDHHmmss)
 // Rejects timestamps before 1996-03-01 (when Wayback started) or in the future
-func isValidArchiveTimestamp(timestamp string) bool {
+func parseArchiveTimestamp(timestamp string) (time.Time, bool) {
     if len(timestamp) != 14 {
-        return false  // Must be exactly 14 characters
+        return time.Time{}, false  // Must be exactly 14 characters
     }
 
     // Parse timestamp: YYYYMMDDHHmmss
     t, err := time.Parse("20060102150405", timestamp)
     if err != nil {
-        return false  // Invalid format
+        return time.Time{}, false  // Invalid format
     }
 
     // Wayback Machine started on March 1, 1996
     waybackStart := time.Date(1996, 3, 1, 0, 0, 0, 0, time.UTC)
     if t.Before(waybackStart) {
-        return false  // Too old
+        return time.Time{}, false  // Too old
     }
 
     // Reject future timestamps (with 7 day buffer for timezone/indexing issues)
     // The Wayback API sometimes returns timestamps slightly ahead due to processing
     futureLimit := time.Now().UTC().Add(7 * 24 * time.Hour)
     if t.After(futureLimit) {
-        return false  // In the future
+        return time.Time{}, false  // In the future
+    }
+
+    return t, true
+}
+
+// archiveAge describes how long ago a snapshot was taken, e.g. "3 years ago".
+// It is the English of archive_age in the JSON API; the HTML page uses
+// localizedAge.
+func archiveAge(t, now time.Time) string {
+    switch n, unit := ageUnits(t, now); n {
+    case 0:
+        return "today"
+    case 1:
+        return fmt.Sprintf("1 %s ago", unit)
+    default:
+        return fmt.Sprintf("%d %ss ago", n, unit)
     }
+}
 
-    return true
+// ageUnits rounds the time from t to now down to whole days, months or
+// years, whichever is largest; n is 0 (days) under a day
+func ageUnits(t, now time.Time) (n int, unit string) {
+    days := int(now.Sub(t).Hours() / 24)
+    switch {
+    case days < 1:
+        return 0, "day"
+    case days < 31:
+        return days, "day"
+    case days < 365:
+        return days / 30, "month"
+    default:
+        return days / 365, "year"
+    }
+}
+
+// ArchiveAgeOf is lr's snapshot age in the page's language
+func (d pageData) ArchiveAgeOf(lr linkResult) string {
+    ts, err := time.Parse(time.RFC3339, lr.ArchiveTimestamp)
+    if err != nil {
+        return lr.ArchiveAge
+    }
+    return localizedAge(d.T, ts, time.Now())
 }
@@ -0,0 +1,474 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		code         int
+		original     string
+		wantStatus   string
+		wantCategory string
+	}{
+		{200, "200 OK", "OK", liveCategorySuccess},
+		{206, "206 Partial Content", "OK", liveCategorySuccess},
+		{301, "301 Moved Permanently", "301 Moved Permanently", liveCategoryRedirect},
+		{403, "403 Forbidden", "403 Forbidden", liveCategoryForbidden},
+		{404, "404 Not Found", "404 Not Found", liveCategoryClientError},
+		{410, "410 Gone", "410 Gone", liveCategoryClientError},
+		{429, "429 Too Many Requests", "429 Rate Limited", liveCategoryRateLimited},
+		{500, "500 Internal Server Error", "500 Internal Server Error", liveCategoryServerError},
+		{503, "503 Service Unavailable", "503 Service Unavailable", liveCategoryServerError},
+	}
+	for _, tt := range tests {
+		status, category := classifyStatus(tt.code, tt.original)
+		if status != tt.wantStatus || category != tt.wantCategory {
+			t.Errorf("classifyStatus(%d) = %q, %q; want %q, %q", tt.code, status, category, tt.wantStatus, tt.wantCategory)
+		}
+	}
+}
+
+func TestCheckLive(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/throttled", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	cfg := testConfig()
+	cfg.ResponseTimeout = 100 * time.Millisecond
+	useConfig(t, cfg)
+
+	tests := []struct {
+		name         string
+		url          string
+		wantCode     int
+		wantStatus   string
+		wantCategory string
+		wantChain    int
+	}{
+		{"success", srv.URL + "/ok", 200, "OK", liveCategorySuccess, 0},
+		{"not found", srv.URL + "/missing", 404, "404 Not Found", liveCategoryClientError, 0},
+		{"rate limited", srv.URL + "/throttled", 429, "429 Rate Limited", liveCategoryRateLimited, 0},
+		{"server error", srv.URL + "/broken", 500, "500 Internal Server Error", liveCategoryServerError, 0},
+		{"redirect followed", srv.URL + "/moved", 200, "OK", liveCategorySuccess, 2},
+		{"timeout", srv.URL + "/slow", 0, "timeout", liveCategoryNetworkError, 0},
+		{"connection refused", closed.URL + "/ok", 0, "connection refused", liveCategoryNetworkError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkLive(context.Background(), tt.url)
+			if got.Code != tt.wantCode || got.Status != tt.wantStatus || got.Category != tt.wantCategory {
+				t.Errorf("checkLive = %d %q (%s); want %d %q (%s)", got.Code, got.Status, got.Category, tt.wantCode, tt.wantStatus, tt.wantCategory)
+			}
+			if len(got.RedirectChain) != tt.wantChain {
+				t.Errorf("redirect chain = %v; want %d hops", got.RedirectChain, tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestCheckLiveBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	cfg := DefaultConfig()
+	cfg.AllowedPorts = []int{}
+	useConfig(t, cfg)
+
+	got := checkLive(context.Background(), srv.URL)
+	if got.Status != errPrivateAddress.Error() || got.Category != liveCategorySkipped {
+		t.Errorf("checkLive(%s) = %q (%s); want %q (skipped)", srv.URL, got.Status, got.Category, errPrivateAddress)
+	}
+}
+
+// waybackResponse is an availability API answer for one snapshot
+func waybackResponse(status string) string {
+	return `{"archived_snapshots": {"closest": {"available": true, "url": "http://web.archive.org/web/20200101000000/http://example.com/", "timestamp": "20200101000000", "status": "` + status + `"}}}`
+}
+
+func TestLookupNear(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantFound  bool
+		wantStatus string
+		wantErr    bool
+	}{
+		{"success", 200, waybackResponse("200"), true, "200", false},
+		{"bad snapshot status", 200, waybackResponse("404"), false, "snapshot has bad status: 404", false},
+		{"not archived", 200, `{"archived_snapshots": {}}`, false, "not archived", false},
+		{"not found", 404, "", false, "HTTP 404 Not Found", false},
+		{"rate limited", 429, "", false, "HTTP 429 Too Many Requests", false},
+		{"malformed JSON", 200, `{"archived_snapshots": `, false, "decode error: unexpected end of JSON input", false},
+		{"timeout", 0, "", false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.WaybackTimeout = 100 * time.Millisecond
+			stubUpstream(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/wayback/available" || r.URL.Query().Get("url") != "http://example.com/" {
+					t.Errorf("unexpected request %s%s", r.Host, r.URL)
+				}
+				if tt.status == 0 {
+					<-r.Context().Done()
+					return
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+
+			snap, err := WaybackProvider{}.LookupNear(context.Background(), "http://example.com/", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v; want error %v", err, tt.wantErr)
+			}
+			if snap.Found != tt.wantFound || snap.Status != tt.wantStatus {
+				t.Errorf("LookupNear = found %v, status %q; want %v, %q", snap.Found, snap.Status, tt.wantFound, tt.wantStatus)
+			}
+			if tt.wantFound && snap.URL != "http://web.archive.org/web/20200101000000/http://example.com/" {
+				t.Errorf("archive URL = %q", snap.URL)
+			}
+		})
+	}
+}
+
+func TestIsArchiveURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://web.archive.org/web/2020/http://example.com/", true},
+		{"http://archive.org/web/2020/http://example.com/", true},
+		{"https://archive.ph/AbCdE", true},
+		{"https://ghostarchive.org/archive/AbCdE", true},
+		{"https://webcache.googleusercontent.com/search?q=cache:example.com", true},
+		{"http://cc.bingj.com/cache.aspx?d=1", true},
+		{"https://www.webcitation.org/6abc", true},
+		{"https://WEB.ARCHIVE.ORG./web/2020/http://example.com/", true},
+		{"https://archive.org/details/texts", false},
+		{"https://example.com/?ref=archive.org", false},
+		{"https://example.com/web.archive.org/page", false},
+		{"https://notarchive.ph/x", false},
+		{"https://archive.ph.example.com/x", false},
+		{"https://www.blink182.com/history", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := isArchiveURL(tt.url); got != tt.want {
+			t.Errorf("isArchiveURL(%q) = %v; want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestExtractBracketedLinks(t *testing.T) {
+	useConfig(t, testConfig())
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{`[http://www.altpress.com/features/entry/qa_mark_hoppus/ Q&A: Mark Hoppus]`, []string{"http://www.altpress.com/features/entry/qa_mark_hoppus/"}},
+		{`[https://example.com/a]`, []string{"https://example.com/a"}},
+		{`Smith. [https://example.com/a ''Title''], 2019.`, []string{"https://example.com/a"}},
+		{`[//example.com/a Title]`, []string{"https://example.com/a"}},
+		{`[https://example.com/a One] and [https://example.com/b Two]`, []string{"https://example.com/a", "https://example.com/b"}},
+		{`[[Blink-182|the band]]`, nil},
+		{`[not a link]`, nil},
+	}
+	for _, tt := range tests {
+		got, _ := extractURLsFromContent(tt.content)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extractURLsFromContent(%q) = %q; want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestCleanURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/page", "https://example.com/page"},
+		{"https://example.com/page.", "https://example.com/page"},
+		{"https://example.com/page.html", "https://example.com/page.html"},
+		{"https://example.com/page,", "https://example.com/page"},
+		{"https://example.com/page).", "https://example.com/page"},
+		{"https://en.wikipedia.org/wiki/Foo_(disambiguation)", "https://en.wikipedia.org/wiki/Foo_(disambiguation)"},
+		{"https://en.wikipedia.org/wiki/Foo_(disambiguation).", "https://en.wikipedia.org/wiki/Foo_(disambiguation)"},
+		{"https://en.wikipedia.org/wiki/Foo_(disambiguation))", "https://en.wikipedia.org/wiki/Foo_(disambiguation)"},
+		{"https://example.com/a_(b_(c))", "https://example.com/a_(b_(c))"},
+		{"https://example.com/?q=[1]", "https://example.com/?q=[1]"},
+		{"https://example.com/page]", "https://example.com/page"},
+		{`https://example.com/page'"`, "https://example.com/page"},
+		{"  https://example.com/page  ", "https://example.com/page"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanURL(tt.in); got != tt.want {
+			t.Errorf("cleanURL(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtractProtocolRelativeURLs(t *testing.T) {
+	useConfig(t, testConfig())
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{`{{cite web |url=//example.com/x |title=X}}`, []string{"https://example.com/x"}},
+		{`{{cite web|url=//example.com/x}}`, []string{"https://example.com/x"}},
+		{`{{cite web |url=www.example.com/x |title=X}}`, []string{"https://www.example.com/x"}},
+		{`[//example.com x]`, []string{"https://example.com"}},
+		{`{{cite web |url=example.com/x}}`, nil},
+		{`{{cite web |url=/relative/x}}`, nil},
+	}
+	for _, tt := range tests {
+		got, _ := extractURLsFromContent(tt.content)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extractURLsFromContent(%q) = %q; want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestIsIgnoredURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		patterns []string
+		want     bool
+	}{
+		{"https://en.wikipedia.org/wiki/Blink-182", defaultIgnoredHosts, true},
+		{"https://commons.wikimedia.org/wiki/File:X.jpg", defaultIgnoredHosts, true},
+		{"https://www.wikidata.org/wiki/Q20", defaultIgnoredHosts, true},
+		{"https://www.wikidata.org", defaultIgnoredHosts, true},
+		{"https://commons.wikimedia.org", defaultIgnoredHosts, true},
+		{"https://commons.wikimedia.org?uselang=de", defaultIgnoredHosts, true},
+		{"https://en.wikipedia.org", defaultIgnoredHosts, false},
+		{"https://en.wikipedia.org/w/index.php?title=X", defaultIgnoredHosts, false},
+		{"https://example.com/wikipedia.org/wiki/Foo", defaultIgnoredHosts, false},
+		{"https://example.com/?ref=wikidata.org", defaultIgnoredHosts, false},
+		{"https://notwikimedia.org/x", defaultIgnoredHosts, false},
+		{"https://wikimedia.org.example.com/x", defaultIgnoredHosts, false},
+		{"https://books.google.com/books?id=1", []string{"google.com"}, true},
+		{"https://GOOGLE.com/", []string{"google.com"}, true},
+		{"https://en.wikipedia.org/wiki/Blink-182", []string{"google.com"}, false},
+		{"https://example.com/private/x", []string{"example.com/private"}, true},
+		{"https://example.com/public/x", []string{"example.com/private"}, false},
+		{"https://example.com/x", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isIgnoredURL(tt.url, tt.patterns); got != tt.want {
+			t.Errorf("isIgnoredURL(%q, %q) = %v; want %v", tt.url, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestParseCitationsIgnoredHosts(t *testing.T) {
+	cfg := testConfig()
+	cfg.IgnoredHosts = []string{"books.google.com"}
+	useConfig(t, cfg)
+
+	cm := ParseCitations(`<ref>https://books.google.com/books?id=1</ref><ref>https://en.wikipedia.org/wiki/Blink-182</ref>`)
+	if got := cm.GetUniqueURLs(); !reflect.DeepEqual(got, []string{"https://en.wikipedia.org/wiki/Blink-182"}) {
+		t.Errorf("URLs = %q", got)
+	}
+	if !reflect.DeepEqual(cm.Ignored, []string{"https://books.google.com/books?id=1"}) {
+		t.Errorf("Ignored = %q", cm.Ignored)
+	}
+}
+
+func TestCheckLiveGETFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		fallback   []int
+		headStatus int
+		wantCode   int
+		wantGETs   int32 // Over two checks of the same URL
+		wantHEADs  int32
+	}{
+		{"HEAD rejected, GET remembered", []int{403}, 403, 200, 2, 1},
+		{"HEAD not allowed", []int{405}, 405, 200, 2, 1},
+		{"status not in list", []int{405}, 403, 403, 0, 2},
+		{"fallback disabled", []int{}, 403, 403, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var heads, gets atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					heads.Add(1)
+					w.WriteHeader(tt.headStatus)
+					return
+				}
+				gets.Add(1)
+			}))
+			defer srv.Close()
+			cfg := testConfig()
+			cfg.GETFallbackStatuses = tt.fallback
+			useConfig(t, cfg)
+
+			for i := 0; i < 2; i++ {
+				if got := checkLive(context.Background(), srv.URL); got.Code != tt.wantCode {
+					t.Errorf("check %d: checkLive = %d %q; want %d", i+1, got.Code, got.Status, tt.wantCode)
+				}
+			}
+			if heads.Load() != tt.wantHEADs || gets.Load() != tt.wantGETs {
+				t.Errorf("server saw %d HEAD and %d GET requests; want %d and %d", heads.Load(), gets.Load(), tt.wantHEADs, tt.wantGETs)
+			}
+		})
+	}
+}
+
+func TestParseCitationsReuseCount(t *testing.T) {
+	useConfig(t, testConfig())
+	wikitext := `Intro.<ref name="ap">[http://www.altpress.com/a Q&A]</ref>
+Formed.<ref name="ap" /> Toured.<ref name=ap/> Split.<ref name="rs">https://www.rollingstone.com/b</ref>
+Again.<ref name='ap'/> Early.<ref name="late"/> Unnamed.<ref>https://example.com/c</ref>
+Late.<ref name="late">https://example.com/d</ref> Redefined.<ref name="rs">https://www.rollingstone.com/b</ref>`
+
+	cm := ParseCitations(wikitext)
+	want := map[string]int{
+		"ap":   3,
+		"rs":   1, // A repeated definition counts as a reuse
+		"late": 1, // Reused before its definition
+		"":     0,
+	}
+	if len(cm.Citations) != len(want) {
+		t.Fatalf("got %d citations; want %d", len(cm.Citations), len(want))
+	}
+	for _, c := range cm.Citations {
+		if c.ReuseCount != want[c.Name] {
+			t.Errorf("citation %d (%q) ReuseCount = %d; want %d", c.Number, c.Name, c.ReuseCount, want[c.Name])
+		}
+	}
+}
+
+func TestExtractSkipsInactiveMarkup(t *testing.T) {
+	useConfig(t, testConfig())
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{`{{cite web |url=https://example.com/live <!-- |url=https://example.com/old -->}}`, []string{"https://example.com/live"}},
+		{`<!--{{cite web |url=https://example.com/old}}-->https://example.com/live`, []string{"https://example.com/live"}},
+		{`<nowiki>https://example.com/literal</nowiki> https://example.com/live`, []string{"https://example.com/live"}},
+		{`<NOWIKI>[https://example.com/literal x]</NOWIKI>`, nil},
+		{`https://example.com/live <!-- unclosed https://example.com/old`, []string{"https://example.com/live"}},
+		{`<!-- <nowiki> -->https://example.com/live</nowiki>`, []string{"https://example.com/live"}},
+	}
+	for _, tt := range tests {
+		got, _ := extractURLsFromContent(tt.content)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("extractURLsFromContent(%q) = %q; want %q", tt.content, got, tt.want)
+		}
+	}
+
+	cm := ParseCitations(`<ref><!-- https://example.com/old --></ref><ref>https://example.com/live</ref>`)
+	if got := cm.GetUniqueURLs(); !reflect.DeepEqual(got, []string{"https://example.com/live"}) {
+		t.Errorf("ParseCitations URLs = %q", got)
+	}
+}
+
+func TestGetUniqueURLsSorted(t *testing.T) {
+	useConfig(t, testConfig())
+	wikitext := `<ref>https://z.example.com/</ref><ref>https://a.example.com/</ref>` +
+		`<ref name="m">https://m.example.com/ https://b.example.com/</ref><ref name="m"/><ref>https://a.example.com/</ref>`
+	want := []string{"https://a.example.com/", "https://b.example.com/", "https://m.example.com/", "https://z.example.com/"}
+	for i := 0; i < 20; i++ {
+		if got := ParseCitations(wikitext).GetUniqueURLs(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: GetUniqueURLs = %q; want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestHandlerLinksKeepScanParameters(t *testing.T) {
+	var base string
+	stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/w/api.php":
+			// Revision 4 is the added_since baseline, without the link
+			wikitext := "<ref>" + base + "/ok</ref>"
+			if r.URL.Query().Get("oldid") == "4" {
+				wikitext = ""
+			}
+			fmt.Fprintf(w, `{"parse": {"title": "Foo", "revid": 5, "wikitext": {"*": %q}}}`, wikitext)
+		case "/wayback/available":
+			w.Write([]byte(`{"archived_snapshots": {}}`))
+		}
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	base = srv.URL
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"added since", url.Values{"page": {"Foo"}, "added_since": {"4"}, "lang": {"de"}}},
+		{"archive only", url.Values{"page": {"Foo"}, "archive_only": {"1"}, "lang": {"fr"}}},
+		{"old revision", url.Values{"page": {"Foo"}, "oldid": {"5"}}},
+	}
+	hrefPattern := regexp.MustCompile(`href="(\?[^"]*)"`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler(rec, httptest.NewRequest(http.MethodGet, "/?"+tt.query.Encode(), nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+
+			links := make(map[string]url.Values) // "view=citation", "format=csv", ...
+			for _, m := range hrefPattern.FindAllStringSubmatch(rec.Body.String(), -1) {
+				q, err := url.ParseQuery(html.UnescapeString(m[1])[1:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, k := range []string{"view", "format"} {
+					if q.Has(k) && !q.Has("limit") && !q.Has("refresh") { // Not the pager or rescan links
+						links[k+"="+q.Get(k)] = q
+					}
+				}
+			}
+			for _, link := range []string{"view=url", "view=citation", "format=csv", "format=tsv"} {
+				q, ok := links[link]
+				if !ok {
+					t.Errorf("no %s link", link)
+					continue
+				}
+				for k := range tt.query {
+					if q.Get(k) != tt.query.Get(k) {
+						t.Errorf("%s link has %s=%q; want %q", link, k, q.Get(k), tt.query.Get(k))
+					}
+				}
+			}
+		})
+	}
+}
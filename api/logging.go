@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// Log formats accepted by Config.LogFormat
+const (
+	LogFormatText = "text" // key=value lines, the default
+	LogFormatJSON = "json" // one JSON object per line
+)
+
+// baseLogger is the package logger, rebuilt by SetConfig.
+// Guarded by configMu.
+var baseLogger = newLogger(DefaultConfig())
+
+// newLogger builds the package logger. Per-link progress logs at info;
+// individual upstream requests and raw response bodies only at debug.
+func newLogger(c Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: c.LogLevel}
+	if c.LogFormat == LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+type loggerKey struct{}
+
+// contextLogger returns the request-scoped logger carried in ctx,
+// or the package logger if there is none
+func contextLogger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return baseLogger
+}
+
+// logFor returns the context logger tagged with a component
+// ("scan", "live", "wayback", ...)
+func logFor(ctx context.Context, component string) *slog.Logger {
+	return contextLogger(ctx).With("component", component)
+}
+
+// withLogAttrs returns a context whose logger carries extra attributes,
+// e.g. a scan_id shared by every log line of one scan
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, contextLogger(ctx).With(args...))
+}
+
+// requestIDHeader carries a request's ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// RequestID tags each request with an ID: the caller's X-Request-ID if it
+// looks sane, otherwise a fresh one. The ID is echoed in the response's
+// X-Request-ID and added to every log line of the request as request_id,
+// so a user's report can be traced in the logs. The caller's address (see
+// clientIP) goes with it as client.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withLogAttrs(r.Context(), "request_id", id, "client", clientIP(r, currentConfig()))))
+	})
+}
+
+// validRequestID accepts inbound IDs of up to 128 letters, digits and
+// -_.: so a client can't inject arbitrary text into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type scanIDKey struct{}
+
+// withScanID tags the context logger with a fresh scan_id, unless an outer
+// caller (e.g. scan-and-archive) already started one
+func withScanID(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(scanIDKey{}).(string); ok {
+		return ctx
+	}
+	id := newCorrelationID()
+	return withLogAttrs(context.WithValue(ctx, scanIDKey{}, id), "scan_id", id)
+}
+
+// newCorrelationID returns a short random ID for tying log lines together
+func newCorrelationID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
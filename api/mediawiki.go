@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// mediaWikiAPI is the endpoint all page lookups go through
+	mediaWikiAPI = "https://en.wikipedia.org/w/api.php"
+
+	// mediaWikiMaxPages caps how many continuation requests a single query
+	// may make, so a misbehaving response can't loop forever
+	mediaWikiMaxPages = 20
+
+	// mediaWikiMaxLag asks MediaWiki to refuse requests while replication
+	// lag exceeds this many seconds; such refusals are retried
+	mediaWikiMaxLag = "5"
+	// mediaWikiRetries is how many times a transient failure (5xx, 429,
+	// maxlag) is retried
+	mediaWikiRetries = 3
+	// mediaWikiMaxRetryWait caps a single backoff or Retry-After wait
+	mediaWikiMaxRetryWait = 30 * time.Second
+)
+
+// mediaWikiErrorEnvelope is the error object MediaWiki returns in place of
+// a result, e.g. {"error": {"code": "missingtitle", "info": "..."}}
+type mediaWikiErrorEnvelope struct {
+	Error *struct {
+		Code string `json:"code"`
+		Info string `json:"info"`
+	} `json:"error"`
+}
+
+// mediaWikiErrorMessages are readable messages for common MediaWiki error
+// codes; %s is the page title
+var mediaWikiErrorMessages = map[string]string{
+	"missingtitle":    "page '%s' does not exist",
+	"invalidtitle":    "'%s' is not a valid page title",
+	"pagecannotexist": "page '%s' cannot exist: it is in a special or virtual namespace",
+	"readapidenied":   "the wiki does not allow reading page '%s' through its API",
+}
+
+// mediaWikiErrorMessage describes a MediaWiki error for users: a readable
+// message for known codes, otherwise the code and MediaWiki's own info
+func mediaWikiErrorMessage(code, info, page string) string {
+	if format, ok := mediaWikiErrorMessages[code]; ok && page != "" {
+		return fmt.Sprintf(format, strings.ReplaceAll(page, "_", " "))
+	}
+	if info != "" {
+		return fmt.Sprintf("mediawiki api error (%s): %s", code, info)
+	}
+	return fmt.Sprintf("mediawiki api error (%s)", code)
+}
+
+// errStopPaging, returned from a fetchMediaWiki callback, ends the loop
+// without an error once the caller has what it needs
+var errStopPaging = errors.New("stop paging")
+
+// fetchMediaWiki calls the MediaWiki API and follows "continue" tokens until
+// the result set is exhausted or mediaWikiMaxPages is reached. Each response
+// body is handed to onPage along with its HTTP status; returning an error
+// from onPage stops the loop (errStopPaging stops it cleanly). Actions that
+// don't paginate (e.g. parse) make exactly one request.
+func fetchMediaWiki(ctx context.Context, params url.Values, onPage func(body []byte, status int) error) error {
+	cfg := currentConfig()
+
+	v := url.Values{}
+	for k, vals := range params {
+		v[k] = append([]string(nil), vals...)
+	}
+	v.Set("format", "json")
+	// set origin to please CORS and some edge policies; harmless for server-side
+	v.Set("origin", "*")
+	v.Set("maxlag", mediaWikiMaxLag)
+	title := params.Get("page")
+	if title == "" {
+		title = params.Get("titles")
+	}
+
+	for page := 1; ; page++ {
+		body, status, err := getMediaWiki(ctx, mediaWikiAPI+"?"+v.Encode(), title, cfg)
+		if err != nil {
+			return err
+		}
+		logFor(ctx, "mediawiki").Debug("response", "page_num", page, "status_code", status)
+
+		if err := onPage(body, status); err != nil {
+			if errors.Is(err, errStopPaging) {
+				return nil
+			}
+			return err
+		}
+
+		var cont struct {
+			Continue map[string]any `json:"continue"`
+		}
+		if err := json.Unmarshal(body, &cont); err != nil || len(cont.Continue) == 0 {
+			return nil
+		}
+		if page >= mediaWikiMaxPages {
+			logFor(ctx, "mediawiki").Warn("stopping at continuation cap", "pages", page)
+			return nil
+		}
+		for k, val := range cont.Continue {
+			v.Set(k, fmt.Sprint(val))
+		}
+	}
+}
+
+// mediaWikiParse is the part of an action=parse result a scan reads
+type mediaWikiParse struct {
+	Title     string `json:"title"`
+	RevID     int64  `json:"revid"`
+	Redirects []struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"redirects"`
+	Wikitext struct {
+		Content string `json:"*"`
+	} `json:"wikitext"`
+	Templates []struct {
+		Name string `json:"*"`
+	} `json:"templates"`
+}
+
+// merge adds one continuation page to p. Lists (templates, redirects)
+// accumulate and wikitext chunks are joined in order; the title and
+// revision come from the first page that has them.
+func (p *mediaWikiParse) merge(page mediaWikiParse) {
+	if p.Title == "" {
+		p.Title = page.Title
+	}
+	if p.RevID == 0 {
+		p.RevID = page.RevID
+	}
+	p.Redirects = append(p.Redirects, page.Redirects...)
+	p.Templates = append(p.Templates, page.Templates...)
+	if page.Wikitext.Content != "" {
+		if p.Wikitext.Content != "" {
+			p.Wikitext.Content += "\n"
+		}
+		p.Wikitext.Content += page.Wikitext.Content
+	}
+}
+
+// fetchParse runs an action=parse request, merging every continuation page
+// into one result
+func fetchParse(ctx context.Context, params url.Values) (mediaWikiParse, error) {
+	logger := logFor(ctx, "mediawiki")
+	var parsed mediaWikiParse
+	err := fetchMediaWiki(ctx, params, func(body []byte, status int) error {
+		var page struct {
+			Parse mediaWikiParse `json:"parse"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			// include a snippet of the payload to aid debugging (common case: missing UA -> HTML/plaintext)
+			snippet := string(body)
+			if len(snippet) > 240 {
+				snippet = snippet[:240] + "..."
+			}
+			logger.Error("decoding MediaWiki response failed", "status_code", status, "error", err)
+			return &apiError{msg: "mediawiki api decode", status: status, payload: snippet}
+		}
+		parsed.merge(page.Parse)
+		return nil
+	})
+	return parsed, err
+}
+
+// getMediaWiki makes one API request, retrying transient failures (5xx,
+// 429 and maxlag refusals) with exponential backoff or the server's
+// Retry-After. An error envelope in the response becomes an *apiError
+// carrying its code and info (see isPageMissing), with a message naming
+// title.
+func getMediaWiki(ctx context.Context, reqURL, title string, cfg Config) ([]byte, int, error) {
+	logger := logFor(ctx, "mediawiki")
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("User-Agent", cfg.UserAgent)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			logger.Warn("request failed", "error", err)
+			return nil, 0, err
+		}
+		body, err := readBody(resp.Body, cfg.MaxResponseBytes)
+		resp.Body.Close()
+		if err != nil {
+			logger.Warn("reading response failed", "error", err)
+			return nil, resp.StatusCode, err
+		}
+
+		var env mediaWikiErrorEnvelope
+		json.Unmarshal(body, &env)
+
+		transient := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests ||
+			(env.Error != nil && env.Error.Code == "maxlag")
+		if transient && attempt < mediaWikiRetries {
+			wait := retryAfter(resp.Header.Get("Retry-After"), backoff, mediaWikiMaxRetryWait)
+			logger.Warn("transient error, retrying", "status_code", resp.StatusCode, "attempt", attempt+1, "wait", wait)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, resp.StatusCode, ctx.Err()
+			}
+			backoff *= 2
+			continue
+		}
+
+		if env.Error != nil {
+			logger.Warn("api error", "code", env.Error.Code, "info", env.Error.Info)
+			ae := &apiError{
+				msg:  mediaWikiErrorMessage(env.Error.Code, env.Error.Info, title),
+				code: env.Error.Code,
+				info: env.Error.Info,
+			}
+			if resp.StatusCode != http.StatusOK {
+				ae.status = resp.StatusCode
+			}
+			return nil, resp.StatusCode, ae
+		}
+		return body, resp.StatusCode, nil
+	}
+}
+
+// isPageMissing reports whether err is MediaWiki saying the page (or the
+// requested revision of it) doesn't exist, as opposed to a transient or
+// upstream failure
+func isPageMissing(err error) bool {
+	var ae *apiError
+	return errors.As(err, &ae) && (ae.code == "missingtitle" || ae.code == "nosuchrevid")
+}
+
+// retryAfter returns the wait a Retry-After header asks for (seconds or an
+// HTTP date), or fallback when it is absent; either is capped at limit
+func retryAfter(header string, fallback, limit time.Duration) time.Duration {
+	wait := fallback
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		wait = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		wait = time.Until(t)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFetchParseFollowsContinuation(t *testing.T) {
+	pages := []string{"testdata/mediawiki_parse_page1.json", "testdata/mediawiki_parse_page2.json"}
+	requests := 0
+	stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "en.wikipedia.org" || r.URL.Path != "/w/api.php" {
+			t.Errorf("unexpected request %s%s", r.Host, r.URL)
+		}
+		q := r.URL.Query()
+		if requests > 0 && (q.Get("tlcontinue") != "10|Cite_web" || q.Get("continue") != "||") {
+			t.Errorf("request %d doesn't carry the continue tokens: %s", requests+1, r.URL.RawQuery)
+		}
+		if requests >= len(pages) {
+			t.Fatalf("request %d after the last page", requests+1)
+		}
+		body, err := os.ReadFile(pages[requests])
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests++
+		w.Write(body)
+	}))
+
+	v := url.Values{}
+	v.Set("action", "parse")
+	v.Set("page", "Blink-182")
+	v.Set("prop", "wikitext|templates")
+	parsed, err := fetchParse(context.Background(), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests; want 2", requests)
+	}
+	if parsed.Title != "Blink-182" || parsed.RevID != 1183312345 {
+		t.Errorf("title, revision = %q, %d", parsed.Title, parsed.RevID)
+	}
+
+	var templates []string
+	for _, tl := range parsed.Templates {
+		templates = append(templates, tl.Name)
+	}
+	wantTemplates := []string{"Template:Cite web", "Template:Citation", "Template:Cite news"}
+	if !reflect.DeepEqual(templates, wantTemplates) {
+		t.Errorf("templates = %q; want %q", templates, wantTemplates)
+	}
+
+	// Links from both pages are found, and the reuse on page 2 points back
+	// at the named ref on page 1
+	cm := ParseCitations(parsed.Wikitext.Content)
+	wantURLs := []string{
+		"http://www.altpress.com/features/entry/qa_mark_hoppus/",
+		"http://www.blink182.com/history",
+		"https://www.rollingstone.com/music/blink-182-enema",
+	}
+	if got := cm.GetUniqueURLs(); !reflect.DeepEqual(got, wantURLs) {
+		t.Errorf("URLs = %q; want %q", got, wantURLs)
+	}
+	if len(cm.Citations) != 3 {
+		t.Errorf("got %d citations; want 3", len(cm.Citations))
+	}
+}
+
+func TestFetchParseSinglePage(t *testing.T) {
+	requests := 0
+	stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"parse": {"title": "Foo", "revid": 7, "wikitext": {"*": "text"}}}`))
+	}))
+	v := url.Values{}
+	v.Set("action", "parse")
+	v.Set("page", "Foo")
+	parsed, err := fetchParse(context.Background(), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 || parsed.Title != "Foo" || parsed.RevID != 7 || parsed.Wikitext.Content != "text" {
+		t.Errorf("got %d requests, %+v", requests, parsed)
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mementoTimeGate is the Time Travel aggregator's TimeGate, which searches
+// many Memento-compliant archives (IA, archive.today, arquivo.pt, ...)
+const mementoTimeGate = "https://timetravel.mementoweb.org/timegate/"
+
+// mementoResult is the best memento found across archives
+type mementoResult struct {
+	URL      string
+	Host     string    // Archive that holds the memento
+	Datetime time.Time // Capture time
+}
+
+// linkEntry is one entry of an RFC 8288 Link header
+type linkEntry struct {
+	URI    string
+	Rels   []string
+	Params map[string]string
+}
+
+func (e linkEntry) hasRel(rel string) bool {
+	for _, r := range e.Rels {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMemento asks the Memento aggregator's TimeGate for the memento closest
+// to now (RFC 7089). found is false when no archive holds the URL.
+func checkMemento(ctx context.Context, raw string) (mementoResult, bool, error) {
+	cfg := currentConfig()
+	logger := logFor(ctx, "memento").With("url", raw)
+	ctx, done := withCheckTimeout(ctx, cfg.WaybackTimeout, logger)
+	defer done()
+
+	logger.Debug("checking timegate")
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mementoTimeGate+raw, nil)
+	if err != nil {
+		return mementoResult{}, false, err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Accept-Datetime", time.Now().UTC().Format(http.TimeFormat))
+
+	// The TimeGate answers with a redirect to the memento; we only need its
+	// headers, so don't follow it
+	client := httpClient
+	if c, ok := httpClient.(*http.Client); ok {
+		noFollow := *c
+		noFollow.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		client = &noFollow
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Warn("request failed", "error", err)
+		return mementoResult{}, false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return mementoResult{}, false, nil
+	}
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return mementoResult{}, false, fmt.Errorf("timegate returned HTTP %d", resp.StatusCode)
+	}
+
+	best := bestMemento(parseLinkHeader(resp.Header.Get("Link")), resp.Header.Get("Location"))
+	if best.URL == "" {
+		return mementoResult{}, false, nil
+	}
+	logger.Info("found memento", "archive_url", best.URL, "timestamp", best.Datetime.Format(time.RFC3339))
+	return best, true, nil
+}
+
+// bestMemento picks the most recent memento from the Link header, falling back
+// to the TimeGate's redirect target
+func bestMemento(links []linkEntry, location string) mementoResult {
+	var best mementoResult
+	for _, l := range links {
+		if !l.hasRel("memento") {
+			continue
+		}
+		dt, err := http.ParseTime(l.Params["datetime"])
+		if err != nil {
+			continue
+		}
+		if best.URL == "" || dt.After(best.Datetime) {
+			best = mementoResult{URL: l.URI, Datetime: dt}
+		}
+	}
+	if best.URL == "" && location != "" {
+		best.URL = location
+	}
+	if u, err := url.Parse(best.URL); err == nil {
+		best.Host = strings.ToLower(u.Hostname())
+	}
+	return best
+}
+
+// parseLinkHeader parses an RFC 8288 Link header such as
+//
+//	<http://a/1>; rel="first memento"; datetime="Tue, 20 Jun 2000 18:02:59 GMT", <http://a/2>; rel="memento"
+//
+// Commas inside <...> or quoted strings don't split entries.
+func parseLinkHeader(h string) []linkEntry {
+	var entries []linkEntry
+	for _, part := range splitOutside(h, ',') {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "<") {
+			continue
+		}
+		end := strings.IndexByte(part, '>')
+		if end < 0 {
+			continue
+		}
+		e := linkEntry{URI: part[1:end], Params: make(map[string]string)}
+		for _, param := range splitOutside(part[end+1:], ';') {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok {
+				continue
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			e.Params[key] = value
+			if key == "rel" {
+				e.Rels = strings.Fields(value)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// splitOutside splits s on sep, ignoring separators inside <...> or "..."
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	inAngle, inQuote := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' && !inAngle:
+			inQuote = !inQuote
+		case c == '<' && !inQuote:
+			inAngle = true
+		case c == '>' && !inQuote:
+			inAngle = false
+		case c == sep && !inAngle && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
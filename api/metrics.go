@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are deliberately not labelled by target host: cited URLs are
+// unbounded. Only fixed upstreams (Wayback, SPN) get their own series.
+var (
+	scansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iabot_scans_total",
+		Help: "Page scans by outcome (ok, partial, error).",
+	}, []string{"outcome"})
+
+	linksCheckedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iabot_links_checked_total",
+		Help: "Links checked by verdict.",
+	}, []string{"verdict"})
+
+	liveCheckDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "iabot_live_check_duration_seconds",
+		Help:    "Latency of live checks (HEAD plus any GET fallback).",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	waybackLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iabot_wayback_lookups_total",
+		Help: "Wayback availability lookups by outcome (archived, not_archived, rejected, error), plus Memento fallback hits (memento).",
+	}, []string{"outcome"})
+
+	spnSubmissionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iabot_spn_submissions_total",
+		Help: "Save Page Now submissions by resulting job status.",
+	}, []string{"status"})
+
+	spnQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iabot_spn_queue_depth",
+		Help: "Save Page Now submissions waiting in the local queue.",
+	})
+
+	spnIntervalSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iabot_spn_interval_seconds",
+		Help: "Current spacing between Save Page Now submissions, including any backoff after 429s.",
+	})
+
+	rateLimitedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "iabot_rate_limited_requests_total",
+		Help: "Requests rejected with 429 by the per-client rate limiter.",
+	})
+
+	rateLimitWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "iabot_rate_limiter_wait_seconds",
+		Help:    "Time spent waiting on rate limiters.",
+		Buckets: []float64{0, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"limiter"})
+)
+
+// MetricsHandler serves GET /metrics in the Prometheus exposition format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// needsArchiveVerdicts are the verdicts of links without a good archive:
+// still alive, or already dead with nothing to fall back on
+var needsArchiveVerdicts = []string{verdictAliveUnarchived, verdictDead}
+
+// needsArchiveLink is one entry of /api/needs-archive
+type needsArchiveLink struct {
+	URL             string `json:"url"`
+	Verdict         string `json:"verdict"` // alive-unarchived or dead
+	CitationNumbers []int  `json:"citation_numbers,omitempty"`
+	ResolvedURL     string `json:"resolved_url,omitempty"` // Destination of a shortener link
+	SubmitURL       string `json:"submit_url"`             // What to save: url, or resolved_url with Config.ArchiveResolvedURL
+}
+
+// NeedsArchiveHandler handles GET /api/needs-archive?page=xxx: the page's
+// links that have no archive (verdict alive-unarchived or dead, and not
+// complete, i.e. not already archived by their citation), as a JSON
+// array ready to feed to Save Page Now, or CSV/TSV with &format=. A recent
+// scan of the page is reused unless ?refresh=1. If the scan timed out the
+// list covers the links it got to and X-Scan-Partial is set.
+func NeedsArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scan, _, err := cachedScanPage(r.Context(), page, scanOptions{}, !refreshRequested(r.URL.Query()))
+	if _, partial := asPartialScan(err); partial {
+		w.Header().Set("X-Scan-Partial", "true")
+	} else if err != nil {
+		status := http.StatusBadGateway
+		if isPageMissing(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	cfg := currentConfig()
+	links := make([]needsArchiveLink, 0)
+	for _, lr := range scan.results {
+		if containsString(needsArchiveVerdicts, lr.Verdict) && !lr.Complete {
+			links = append(links, needsArchiveLink{
+				URL:             lr.URL,
+				Verdict:         lr.Verdict,
+				CitationNumbers: lr.CitationNumbers,
+				ResolvedURL:     lr.ResolvedURL,
+				SubmitURL:       archiveTarget(lr, cfg),
+			})
+		}
+	}
+
+	if sep, ext, ok := exportSeparator(r.URL.Query().Get("format")); ok {
+		setExportHeaders(w, exportFilename(page)+"-needs-archive", sep, ext)
+		cw := csv.NewWriter(w)
+		cw.Comma = sep
+		cw.Write([]string{"URL", "Verdict", "Citations", "ResolvedURL", "SubmitURL"})
+		for _, l := range links {
+			nums := make([]string, len(l.CitationNumbers))
+			for i, n := range l.CitationNumbers {
+				nums[i] = strconv.Itoa(n)
+			}
+			cw.Write([]string{l.URL, l.Verdict, strings.Join(nums, " "), l.ResolvedURL, l.SubmitURL})
+		}
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultTrackingParams are query parameters that never change page content.
+// Entries ending in "*" match any parameter with that prefix.
+var defaultTrackingParams = []string{"utm_*", "fbclid", "gclid"}
+
+// normalizeURL returns a dedup key for raw. Equivalent spellings of the same
+// page map to the same key; the original URL is what gets displayed and
+// archived. Unparseable URLs are returned unchanged.
+//
+// Rules: lowercase scheme and host, encode international hosts as punycode,
+// drop default ports and the fragment, treat an empty path as "/", drop
+// tracking params (cfg.TrackingParams) and, if cfg.StripTrailingSlash is
+// set, a trailing slash on non-root paths.
+func normalizeURL(raw string, cfg Config) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := asciiHost(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	} else if cfg.StripTrailingSlash && len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	}
+
+	u.RawQuery = stripTrackingParams(u.RawQuery, cfg.TrackingParams)
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// asciiHost returns host in lowercase ASCII, with internationalized labels
+// encoded as punycode (münchen.example -> xn--mnchen-3ya.example). Hosts
+// that are already encoded come back unchanged; hosts IDNA rejects are only
+// lowercased.
+func asciiHost(host string) string {
+	if a, err := idna.Lookup.ToASCII(host); err == nil {
+		return a
+	}
+	return strings.ToLower(host)
+}
+
+// asciiURL returns raw with its host converted by asciiHost, for making
+// requests; the original form is kept for display. URLs that don't parse
+// or are already ASCII are returned unchanged.
+func asciiURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	hostname := u.Hostname()
+	host := asciiHost(hostname)
+	if host == strings.ToLower(hostname) {
+		return raw
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	return u.String()
+}
+
+// stripTrackingParams removes matching parameters from a raw query string,
+// preserving the order of everything else
+func stripTrackingParams(rawQuery string, params []string) string {
+	if rawQuery == "" || len(params) == 0 {
+		return rawQuery
+	}
+	kept := make([]string, 0)
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key := pair
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key = pair[:i]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if !isTrackingParam(strings.ToLower(key), params) {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func isTrackingParam(key string, params []string) bool {
+	for _, p := range params {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// collapseEquivalentURLs merges URLs that normalize to the same key. The first
+// URL of each group (in input order) is kept as the representative, and the
+// citation numbers of the whole group are merged onto it.
+func collapseEquivalentURLs(urls []string, cm *CitationMap, cfg Config) ([]string, map[string][]int) {
+	reps := make([]string, 0, len(urls))
+	repForKey := make(map[string]string)
+	citations := make(map[string][]int)
+
+	for _, u := range urls {
+		key := normalizeURL(u, cfg)
+		rep, ok := repForKey[key]
+		if !ok {
+			rep = u
+			repForKey[key] = u
+			reps = append(reps, u)
+		}
+		if cm != nil {
+			citations[rep] = mergeCitationNumbers(citations[rep], cm.GetCitationNumbers(u))
+		}
+	}
+	return reps, citations
+}
+
+// mergeCitationNumbers returns the sorted union of two citation number lists
+func mergeCitationNumbers(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[int]struct{}, len(a)+len(b))
+	merged := make([]int, 0, len(a)+len(b))
+	for _, list := range [][]int{a, b} {
+		for _, n := range list {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				merged = append(merged, n)
+			}
+		}
+	}
+	sort.Ints(merged)
+	return merged
+}
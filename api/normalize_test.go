@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		raw, want string
+	}{
+		{"https://münchen.example/Stadt", "https://xn--mnchen-3ya.example/Stadt"},
+		{"https://MÜNCHEN.example/Stadt", "https://xn--mnchen-3ya.example/Stadt"},
+		{"https://xn--mnchen-3ya.example/Stadt", "https://xn--mnchen-3ya.example/Stadt"},
+		{"https://bücher.de:443/", "https://xn--bcher-kva.de/"},
+		{"http://bücher.de:8080/a#top", "http://xn--bcher-kva.de:8080/a"},
+		{"HTTP://Example.COM", "http://example.com/"},
+		{"https://example.com/a?utm_source=x&id=1", "https://example.com/a?id=1"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := normalizeURL(tt.raw, cfg); got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q; want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestASCIIURL(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"https://münchen.example/Straße?q=ü", "https://xn--mnchen-3ya.example/Stra%C3%9Fe?q=ü"},
+		{"https://bücher.de:8443/", "https://xn--bcher-kva.de:8443/"},
+		{"https://Example.com/Path", "https://Example.com/Path"},
+		{"https://xn--mnchen-3ya.example/", "https://xn--mnchen-3ya.example/"},
+	}
+	for _, tt := range tests {
+		if got := asciiURL(tt.raw); got != tt.want {
+			t.Errorf("asciiURL(%q) = %q; want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCollapseEquivalentIDNURLs(t *testing.T) {
+	urls := []string{"https://münchen.example/", "https://xn--mnchen-3ya.example/", "https://example.com/"}
+	got, _ := collapseEquivalentURLs(urls, nil, DefaultConfig())
+	want := []string{"https://münchen.example/", "https://example.com/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collapseEquivalentURLs = %q; want %q", got, want)
+	}
+}
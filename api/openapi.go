@@ -0,0 +1,40 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// openAPISource is the hand-maintained OpenAPI 3 description of the JSON
+// API. Update it alongside any change to a handler's request or response
+// types.
+//
+//go:embed openapi.json
+var openAPISource []byte
+
+// openAPISpec is openAPISource with info.version filled in. It is built
+// once at init, so a malformed spec fails at startup.
+var openAPISpec = func() []byte {
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISource, &spec); err != nil {
+		panic("openapi.json: " + err.Error())
+	}
+	spec["info"].(map[string]any)["version"] = Version
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		panic("openapi.json: " + err.Error())
+	}
+	return b
+}()
+
+// OpenAPIHandler handles GET /api/openapi.json
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(openAPISpec)
+}
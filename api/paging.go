@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// htmlPageSize is how many results the HTML page shows at once
+const htmlPageSize = 20
+
+// resultFilter narrows a scan's results to some verdicts and one page
+type resultFilter struct {
+	verdicts []string // Empty means every verdict
+	complete *bool    // nil means complete and incomplete links alike
+	offset   int
+	limit    int // 0 means no limit
+}
+
+// parseResultFilter reads ?verdict= (comma-separated), ?complete=,
+// ?offset= and ?limit=. defaultLimit applies when limit is absent.
+func parseResultFilter(q url.Values, defaultLimit int) (resultFilter, error) {
+	f := resultFilter{limit: defaultLimit}
+	for _, v := range strings.Split(q.Get("verdict"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			f.verdicts = append(f.verdicts, v)
+		}
+	}
+	if s := q.Get("complete"); s != "" {
+		c, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid complete %q", s)
+		}
+		f.complete = &c
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &f.offset}, {"limit", &f.limit}} {
+		if s := q.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return f, fmt.Errorf("invalid %s %q", p.name, s)
+			}
+			*p.dst = n
+		}
+	}
+	return f, nil
+}
+
+// pagingRequested reports whether q asks for filtering or paging, i.e. whether
+// the caller is navigating a scan it already has
+func pagingRequested(q url.Values) bool {
+	return q.Has("verdict") || q.Has("complete") || q.Has("offset") || q.Has("limit")
+}
+
+// apply returns the page of results the filter selects and how many
+// results matched before paging
+func (f resultFilter) apply(results []linkResult) (page []linkResult, matched int) {
+	page = make([]linkResult, 0)
+	for _, lr := range results {
+		if len(f.verdicts) > 0 && !containsString(f.verdicts, lr.Verdict) {
+			continue
+		}
+		if f.complete != nil && lr.Complete != *f.complete {
+			continue
+		}
+		if matched >= f.offset && (f.limit == 0 || len(page) < f.limit) {
+			page = append(page, lr)
+		}
+		matched++
+	}
+	return page, matched
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// resultPage describes the slice of results a response carries
+type resultPage struct {
+	Verdict  string `json:"verdict,omitempty"`  // Filter as given, comma-separated
+	Complete *bool  `json:"complete,omitempty"` // ?complete= as given
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit,omitempty"`
+	Matched  int    `json:"matched"` // Results matching the filter, across all pages
+}
+
+// pager is the HTML page's view of resultPage: filter buttons plus
+// previous/next links, with every href prebuilt
+type pager struct {
+	resultPage
+	From, To int // 1-based range shown
+	Filters  []verdictFilter
+	// HideComplete toggles ?complete=false; nil when no result is complete
+	HideComplete *verdictFilter
+	PrevURL      template.URL
+	NextURL      template.URL
+}
+
+type verdictFilter struct {
+	Verdict string // Empty for "all"
+	Count   int
+	Active  bool
+	URL     template.URL
+}
+
+// pagerVerdicts are the filter buttons, most actionable first
+var pagerVerdicts = []string{verdictDead, verdictDeadArchived, verdictBlocked, verdictUnknown, verdictAliveUnarchived, verdictAlive}
+
+// link returns the href of the page of results at offset, filtered by
+// verdict and f's other settings. base holds the query parameters every
+// link keeps (page, view, ...).
+func (f resultFilter) link(base url.Values, verdict string, offset int) template.URL {
+	v := url.Values{}
+	for k, vals := range base {
+		v[k] = vals
+	}
+	if verdict != "" {
+		v.Set("verdict", verdict)
+	}
+	if f.complete != nil {
+		v.Set("complete", strconv.FormatBool(*f.complete))
+	}
+	if offset > 0 {
+		v.Set("offset", strconv.Itoa(offset))
+	}
+	v.Set("limit", strconv.Itoa(f.limit))
+	return template.URL("?" + v.Encode())
+}
+
+// withParam returns the href of the page described by base with key set to
+// value, e.g. another view or an export of the same scan
+func withParam(base url.Values, key, value string) template.URL {
+	v := url.Values{}
+	for k, vals := range base {
+		v[k] = vals
+	}
+	v.Set(key, value)
+	return template.URL("?" + v.Encode())
+}
+
+// newPager builds the controls for results filtered by f. base holds the
+// query parameters every link keeps (page, view, ...).
+func newPager(results []linkResult, f resultFilter, matched int, base url.Values) *pager {
+	link := func(verdict string, offset int) template.URL { return f.link(base, verdict, offset) }
+
+	verdict := strings.Join(f.verdicts, ",")
+	p := &pager{resultPage: resultPage{Verdict: verdict, Complete: f.complete, Offset: f.offset, Limit: f.limit, Matched: matched}}
+	if matched > f.offset {
+		p.From = f.offset + 1
+		p.To = matched
+		if f.limit > 0 && f.offset+f.limit < matched {
+			p.To = f.offset + f.limit
+			p.NextURL = link(verdict, f.offset+f.limit)
+		}
+	}
+	if f.offset > 0 && f.limit > 0 {
+		p.PrevURL = link(verdict, max(0, f.offset-f.limit))
+	}
+
+	counts := make(map[string]int)
+	complete := 0
+	for _, lr := range results {
+		counts[lr.Verdict]++
+		if lr.Complete {
+			complete++
+		}
+	}
+	if complete > 0 {
+		// The toggle keeps the verdict but flips ?complete=false on or off
+		toggle := f
+		hidden := f.complete != nil && !*f.complete
+		if hidden {
+			toggle.complete = nil
+		} else {
+			no := false
+			toggle.complete = &no
+		}
+		p.HideComplete = &verdictFilter{Count: complete, Active: hidden, URL: toggle.link(base, verdict, 0)}
+	}
+	p.Filters = append(p.Filters, verdictFilter{Count: len(results), Active: verdict == "", URL: link("", 0)})
+	for _, v := range pagerVerdicts {
+		if counts[v] > 0 {
+			p.Filters = append(p.Filters, verdictFilter{Verdict: v, Count: counts[v], Active: verdict == v, URL: link(v, 0)})
+		}
+	}
+	return p
+}
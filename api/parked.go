@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parkedBodyMaxBytes caps how much of a page we scan for parking markers
+const parkedBodyMaxBytes = 64 * 1024
+
+// parkingNameservers are nameserver domains run by domain parking and
+// resale services
+var parkingNameservers = []string{
+	"sedoparking.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"above.com",
+	"parklogic.com",
+	"dan.com",
+	"afternic.com",
+	"hugedomains.com",
+	"undeveloped.com",
+	"uniregistrymarket.link",
+}
+
+// parkedMarkers are lowercase phrases that show up on parking pages
+var parkedMarkers = [][]byte{
+	[]byte("this domain is for sale"),
+	[]byte("this domain may be for sale"),
+	[]byte("buy this domain"),
+	[]byte("domain is parked"),
+	[]byte("parked free, courtesy of"),
+	[]byte("sedoparking"),
+	[]byte("parkingcrew"),
+	[]byte("hugedomains.com"),
+}
+
+// checkParked guesses whether raw sits on a parked domain: either its
+// nameservers belong to a parking service or the page carries a
+// "for sale" marker. It is a heuristic hint, not a WHOIS lookup.
+func checkParked(ctx context.Context, raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	cfg := currentConfig()
+	logger := logFor(ctx, "parked").With("url", raw)
+	ctx, done := withCheckTimeout(withDestinationGuard(ctx), cfg.LiveTimeout, logger)
+	defer done()
+
+	if ns, ok := parkingNameserver(ctx, strings.ToLower(u.Hostname())); ok {
+		logger.Info("parking nameserver", "nameserver", ns)
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Accept-Encoding", inspectAcceptEncoding)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	// A PDF or image is the site's own content, not a parking page
+	if !isHTMLType(mediaType(resp.Header.Get("Content-Type"))) {
+		return false
+	}
+
+	// Markers sit near the top of a parking page, so a prefix is enough
+	body, err := decodedBody(resp, parkedBodyMaxBytes)
+	if err != nil {
+		logger.Info("skipping body inspection", "error", err)
+		return false
+	}
+	body = bytes.ToLower(body)
+	for _, m := range parkedMarkers {
+		if bytes.Contains(body, m) {
+			logger.Info("parking page marker", "marker", string(m))
+			return true
+		}
+	}
+	return false
+}
+
+// parkingNameserver looks up the NS records of host and its parent domains,
+// returning the first nameserver run by a parking service
+func parkingNameserver(ctx context.Context, host string) (string, bool) {
+	for name := host; strings.Contains(name, "."); {
+		records, err := net.DefaultResolver.LookupNS(ctx, name)
+		if err == nil && len(records) > 0 {
+			for _, r := range records {
+				ns := strings.TrimSuffix(strings.ToLower(r.Host), ".")
+				for _, p := range parkingNameservers {
+					if hostMatches(ns, p) {
+						return ns, true
+					}
+				}
+			}
+			return "", false
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxParseBytes bounds the wikitext /api/parse accepts; Wikipedia itself
+// caps articles at 2 MiB
+const maxParseBytes = 2 << 20
+
+// ParseResponse is the JSON form of a CitationMap, plus what the parser
+// looks for, so parser gaps can be diagnosed without scanning a page
+type ParseResponse struct {
+	Citations     []parsedCitation `json:"citations"`
+	URLToCitation map[string][]int `json:"url_to_citation"` // URL -> numbers of the citations using it
+	NameToNumber  map[string]int   `json:"name_to_number"`  // ref name -> citation number
+	Ignored       []string         `json:"ignored"`         // URLs skipped because of IgnoredHosts
+	IgnoredHosts  []string         `json:"ignored_hosts"`   // Config.IgnoredHosts in effect
+	URLParameters []string         `json:"url_parameters"`  // Template parameters URLs are read from, in any template
+	Templates     []TemplateUsage  `json:"templates"`       // Citation templates called in the wikitext
+}
+
+type parsedCitation struct {
+	Number     int      `json:"number"`
+	Name       string   `json:"name,omitempty"`
+	URLs       []string `json:"urls"`
+	Context    string   `json:"context,omitempty"`
+	ReuseCount int      `json:"reuse_count,omitempty"`
+
+	Sources []CitedSource `json:"sources,omitempty"` // url/archive-url pairs from the citation's templates
+}
+
+// ParseHandler handles POST /api/parse. The body is raw wikitext; the
+// response shows the citations and URLs ParseCitations extracts from it,
+// with the same IgnoredHosts filtering as a scan. Nothing is fetched.
+// Only <ref> contents are parsed: bare URLs, [url text] links and the
+// URLParameters of any template, outside comments and nowiki blocks. Each
+// citation's sources pair a template's url with its archive-url.
+func ParseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxParseBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Wikitext too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) == 0 {
+		http.Error(w, "No wikitext provided", http.StatusBadRequest)
+		return
+	}
+
+	wikitext := string(body)
+	cm := ParseCitations(wikitext)
+	var calls []string
+	for _, m := range templateCallPattern.FindAllStringSubmatch(wikitext, -1) {
+		calls = append(calls, m[1])
+	}
+
+	resp := ParseResponse{
+		Citations:     make([]parsedCitation, 0, len(cm.Citations)),
+		URLToCitation: cm.URLToCitation,
+		NameToNumber:  cm.NameToNumber,
+		Ignored:       cm.Ignored,
+		IgnoredHosts:  currentConfig().IgnoredHosts,
+		URLParameters: templateURLParams,
+		Templates:     citationTemplateUsage(calls, wikitext),
+	}
+	for _, c := range cm.Citations {
+		resp.Citations = append(resp.Citations, parsedCitation{
+			Number:     c.Number,
+			Name:       c.Name,
+			URLs:       c.URLs,
+			Context:    c.Context,
+			ReuseCount: c.ReuseCount,
+			Sources:    c.Sources,
+		})
+	}
+	if resp.Ignored == nil {
+		resp.Ignored = []string{}
+	}
+	if resp.Templates == nil {
+		resp.Templates = []TemplateUsage{}
+	}
+	logFor(r.Context(), "parse").Info("parsed wikitext", "bytes", len(body), "citations", len(resp.Citations))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Archive preview verdicts
+const (
+	PreviewOK          = "ok"          // The snapshot loads and isn't an error page
+	PreviewSoftError   = "soft-error"  // 2xx, but the body is an archive error page
+	PreviewHTTPError   = "http-error"  // The archive answered with a non-2xx status
+	PreviewUnreachable = "unreachable" // No response: timeout, DNS, refused destination, ...
+)
+
+// previewBodyMaxBytes caps how much of a snapshot we scan for error markers.
+// Wayback's error pages are small and say what's wrong near the top.
+const previewBodyMaxBytes = 64 * 1024
+
+// waybackErrorMarkers are lowercase phrases of the pages the Wayback Machine
+// serves, often with status 200, in place of a capture it can't show
+var waybackErrorMarkers = []struct {
+	marker []byte
+	reason string
+}{
+	{[]byte("this snapshot cannot be displayed"), "snapshot cannot be displayed"},
+	{[]byte("the page cannot be displayed"), "page cannot be displayed"},
+	{[]byte("has been excluded from the wayback machine"), "excluded from the Wayback Machine"},
+	{[]byte("page cannot be crawled or displayed due to robots.txt"), "blocked by robots.txt"},
+	{[]byte("wayback machine has not archived that url"), "not archived"},
+	{[]byte("wayback machine doesn't have that page archived"), "not archived"},
+}
+
+// crawlStatusPattern matches Wayback's note that a capture recorded an HTTP
+// error, e.g. "Got an HTTP 404 response at crawl time"
+var crawlStatusPattern = regexp.MustCompile(`got an http (\d{3}) response at crawl time`)
+
+// ArchivePreview is the JSON response of /api/archive/preview
+type ArchivePreview struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url,omitempty"` // Where redirects led, if anywhere
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Renders     bool   `json:"renders"` // Verdict is PreviewOK
+	Verdict     string `json:"verdict"`
+	Reason      string `json:"reason,omitempty"` // Why it doesn't render
+}
+
+// previewArchive fetches an archive URL and decides whether the snapshot
+// actually renders. Unlike Config.CheckArchiveURLs, which only looks at the
+// status, it reads the body for Wayback's soft errors: pages that answer 200
+// but say the snapshot can't be displayed.
+func previewArchive(ctx context.Context, raw string) ArchivePreview {
+	p := ArchivePreview{URL: raw, Verdict: PreviewUnreachable}
+	cfg := currentConfig()
+	logger := logFor(ctx, "preview").With("url", raw)
+	ctx, done := withCheckTimeout(withDestinationGuard(ctx), cfg.LiveTimeout, logger)
+	defer done()
+
+	reqURL := asciiURL(raw)
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		p.Reason = err.Error()
+		return p
+	}
+	if err := checkDestination(ctx, u, cfg); err != nil {
+		logger.Warn("refusing to preview", "reason", err)
+		p.Reason = classifyError(err)
+		return p
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		p.Reason = err.Error()
+		return p
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Accept-Encoding", inspectAcceptEncoding)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		p.Reason = classifyError(err)
+		logger.Info("archive unreachable", "reason", p.Reason)
+		return p
+	}
+	defer resp.Body.Close()
+
+	p.StatusCode = resp.StatusCode
+	p.ContentType = mediaType(resp.Header.Get("Content-Type"))
+	if final := resp.Request.URL.String(); final != reqURL {
+		p.FinalURL = final
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.Verdict = PreviewHTTPError
+		p.Reason = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		logger.Info("archive answered with an error", "status", resp.StatusCode)
+		return p
+	}
+
+	p.Verdict, p.Renders = PreviewOK, true
+	if !isHTMLType(p.ContentType) {
+		return p
+	}
+	body, err := decodedBody(resp, previewBodyMaxBytes)
+	if err != nil {
+		// The status was fine; not being able to read more isn't an error page
+		logger.Info("skipping body inspection", "error", err)
+		return p
+	}
+	if reason := waybackSoftError(bytes.ToLower(body)); reason != "" {
+		p.Verdict, p.Renders, p.Reason = PreviewSoftError, false, reason
+		logger.Info("archive error page", "reason", reason)
+	}
+	return p
+}
+
+// waybackSoftError returns why a lowercased 2xx body is a Wayback error page
+// rather than a capture, or "" if it looks like a capture
+func waybackSoftError(body []byte) string {
+	for _, m := range waybackErrorMarkers {
+		if bytes.Contains(body, m.marker) {
+			return m.reason
+		}
+	}
+	if m := crawlStatusPattern.FindSubmatch(body); m != nil {
+		// Redirect captures say "Got an HTTP 302" too, and are fine
+		if code, _ := strconv.Atoi(string(m[1])); code >= 400 {
+			return fmt.Sprintf("captured an HTTP %d response", code)
+		}
+	}
+	return ""
+}
+
+// ArchivePreviewHandler handles GET /api/archive/preview?url=..., which
+// fetches an archive URL server-side and reports whether the snapshot
+// renders, before it gets recommended. Only archive URLs are accepted, so
+// the endpoint can't be used to fetch arbitrary pages.
+func ArchivePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	raw := strings.TrimSpace(r.URL.Query().Get("url"))
+	if raw == "" {
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+	if !isAbsoluteHTTPURL(raw) || !isArchiveURL(raw) {
+		http.Error(w, fmt.Sprintf("Not an archive URL: %s", raw), http.StatusBadRequest)
+		return
+	}
+
+	p := previewArchive(withScanID(r.Context()), raw)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveProvider looks up existing snapshots of a URL in one web archive.
+// Config.ArchiveProviders lists the providers to consult, in order.
+type ArchiveProvider interface {
+	// Name identifies the provider in results ("wayback", ...)
+	Name() string
+	// Lookup finds the best snapshot of url. A miss is not an error: it
+	// returns Found false with Status saying why. err is for lookups that
+	// could not be completed at all.
+	Lookup(ctx context.Context, url string) (ArchiveSnapshot, error)
+}
+
+// ArchiveSnapshot is one provider's answer for a URL
+type ArchiveSnapshot struct {
+	Found     bool
+	URL       string    // Snapshot URL when found
+	Status    string    // Snapshot HTTP status when found, otherwise why not
+	Timestamp time.Time // Capture time (zero if not found)
+	// SnapshotStatus is the captured HTTP status of the closest snapshot,
+	// also when it was rejected for it (0 if the archive doesn't say)
+	SnapshotStatus int
+}
+
+// defaultArchiveProviders is used when Config.ArchiveProviders is nil
+var defaultArchiveProviders = []ArchiveProvider{WaybackProvider{}}
+
+// knownArchiveProviders maps provider names to providers for
+// ParseArchiveProviders
+var knownArchiveProviders = []ArchiveProvider{WaybackProvider{}, ArchiveTodayProvider{}}
+
+// ParseArchiveProviders turns a comma-separated list of provider names
+// ("wayback,archive.today") into Config.ArchiveProviders, keeping the order.
+// An empty list returns nil, i.e. the defaults.
+func ParseArchiveProviders(names string) ([]ArchiveProvider, error) {
+	var providers []ArchiveProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found ArchiveProvider
+		for _, p := range knownArchiveProviders {
+			if p.Name() == name {
+				found = p
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown archive provider %q", name)
+		}
+		providers = append(providers, found)
+	}
+	return providers, nil
+}
+
+// ParseSnapshotStatuses turns a comma-separated list of HTTP statuses
+// ("200,203,206,301") into Config.AcceptedSnapshotStatuses. "any" accepts
+// every snapshot; an empty list returns nil, i.e. the defaults.
+func ParseSnapshotStatuses(list string) ([]int, error) {
+	if strings.TrimSpace(list) == "any" {
+		return []int{}, nil
+	}
+	return parseStatusCodes(list)
+}
+
+// parseStatusCodes parses a comma-separated list of HTTP status codes
+func parseStatusCodes(list string) ([]int, error) {
+	var statuses []int
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status %q", s)
+		}
+		statuses = append(statuses, code)
+	}
+	return statuses, nil
+}
+
+// checkArchives asks each configured provider in turn and returns the first
+// hit. On a miss the first provider's status is reported. With
+// Config.MementoFallback the Memento aggregator is tried last.
+func checkArchives(ctx context.Context, raw string) waybackResult {
+	cfg := currentConfig()
+
+	var miss waybackResult
+	for i, p := range cfg.ArchiveProviders {
+		snap, err := p.Lookup(ctx, raw)
+		r := waybackResult{
+			Archived:  snap.Found,
+			URL:       snap.URL,
+			Status:    snap.Status,
+			Timestamp: snap.Timestamp,
+			Source:    p.Name(),
+
+			SnapshotStatus: snap.SnapshotStatus,
+		}
+		if err != nil {
+			r = waybackResult{Status: "error: " + err.Error()}
+		}
+		if r.Archived {
+			return r
+		}
+		if i == 0 {
+			miss = r
+		}
+	}
+
+	// Fall back to other Memento-compliant archives
+	if cfg.MementoFallback {
+		if m, found, err := checkMemento(ctx, raw); err == nil && found {
+			waybackLookupsTotal.WithLabelValues("memento").Inc()
+			return waybackResult{Archived: true, URL: m.URL, Status: "memento: " + m.Host, Timestamp: m.Datetime, Source: m.Host}
+		}
+	}
+	return miss
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// clientBucketIdle is how long a client's bucket is kept after its last
+// request; by then it has refilled anyway
+const clientBucketIdle = 10 * time.Minute
+
+// clientLimiter is a token bucket per client IP. Idle buckets are swept
+// while handling requests, so it needs no background goroutine.
+type clientLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var apiClientLimiter = &clientLimiter{buckets: make(map[string]*tokenBucket)}
+
+// allow takes a token from client's bucket, which holds up to burst tokens
+// and refills at perMinute. If none is left it reports how long until one
+// is. remaining is the whole tokens left afterwards.
+func (cl *clientLimiter) allow(client string, perMinute, burst int, now time.Time) (ok bool, remaining int, retryAfter time.Duration) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if now.Sub(cl.lastSweep) > time.Minute {
+		for k, b := range cl.buckets {
+			if now.Sub(b.last) > clientBucketIdle {
+				delete(cl.buckets, k)
+			}
+		}
+		cl.lastSweep = now
+	}
+
+	rate := float64(perMinute) / 60 // Tokens per second
+	b, found := cl.buckets[client]
+	if !found {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		cl.buckets[client] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// RateLimit wraps an expensive endpoint with the per-client limit from
+// Config.ClientRateLimit. Every response carries X-RateLimit-Limit and
+// X-RateLimit-Remaining; over the limit the client gets 429 with
+// Retry-After instead of running next.
+func RateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := currentConfig()
+		if cfg.ClientRateLimit < 0 {
+			next(w, r)
+			return
+		}
+
+		client := clientIP(r, cfg)
+		ok, remaining, retryAfter := apiClientLimiter.allow(client, cfg.ClientRateLimit, cfg.ClientBurst, time.Now())
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(cfg.ClientRateLimit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			secs := int(math.Ceil(retryAfter.Seconds()))
+			rateLimitedTotal.Inc()
+			logFor(r.Context(), "http").Warn("rate limited", "client", client, "path", r.URL.Path, "retry_after", secs)
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			http.Error(w, "Too many requests, retry in "+strconv.Itoa(secs)+"s", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RecheckResult is a re-verified link alongside the verdict it had before
+type RecheckResult struct {
+	linkResult
+	PreviousVerdict string `json:"previous_verdict,omitempty"`
+}
+
+// RecheckResponse is the JSON envelope for /api/recheck
+type RecheckResponse struct {
+	Results []RecheckResult `json:"results"`
+	Skipped int             `json:"skipped"` // Prior results left out because they were already alive
+	Error   *errorBody      `json:"error,omitempty"`
+}
+
+// RecheckHandler handles POST /api/recheck, which re-verifies links from an
+// earlier scan after some have been fixed. The body is a JSON array whose
+// elements are either URLs or result objects copied from /api/scan. Result
+// objects keep their citation numbers and context, and ones whose verdict
+// was alive are skipped unless ?all=1 is given. Links go through the same
+// pipeline as /api/check.
+func RecheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "No URLs provided", http.StatusBadRequest)
+		return
+	}
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+
+	cfg := currentConfig()
+	var urls, invalid []string
+	prior := make(map[string]linkResult) // By normalizeURL key
+	skipped := 0
+	for i, item := range items {
+		var prev linkResult
+		if err := json.Unmarshal(item, &prev.URL); err != nil {
+			if err := json.Unmarshal(item, &prev); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid element %d: %v", i, err), http.StatusBadRequest)
+				return
+			}
+			if !all && isAliveVerdict(prev.Verdict) {
+				skipped++
+				continue
+			}
+		}
+		prev.URL = strings.TrimSpace(prev.URL)
+		if !isAbsoluteHTTPURL(prev.URL) {
+			invalid = append(invalid, prev.URL)
+			continue
+		}
+		urls = append(urls, prev.URL)
+		prior[normalizeURL(prev.URL, cfg)] = prev
+	}
+	if len(invalid) > 0 {
+		http.Error(w, fmt.Sprintf("Not absolute http(s) URLs: %s", strings.Join(invalid, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withScanID(r.Context())
+	out, _ := prepareURLs(ctx, urls, nil, cfg)
+	citationNumbers := make(map[string][]int, len(out))
+	for _, u := range out {
+		citationNumbers[u] = prior[normalizeURL(u, cfg)].CitationNumbers
+	}
+	results, err := checkLinks(ctx, out, citationNumbers, scanOptions{archiveOnly: archiveOnlyRequested(r)})
+
+	resp := RecheckResponse{Results: make([]RecheckResult, 0, len(results)), Skipped: skipped}
+	for _, lr := range results {
+		prev := prior[normalizeURL(lr.URL, cfg)]
+		lr.CitationContext = prev.CitationContext
+		resp.Results = append(resp.Results, RecheckResult{linkResult: lr, PreviousVerdict: prev.Verdict})
+	}
+	logFor(ctx, "scan").Info("recheck complete", "links", len(results), "skipped", skipped)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		resp.Error = newErrorBody(err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// isAliveVerdict reports whether a prior verdict needs no re-verification
+func isAliveVerdict(v string) bool {
+	return v == verdictAlive || v == verdictAliveUnarchived
+}
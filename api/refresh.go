@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// refreshBodyMaxBytes caps how much of a page we scan for client-side
+// redirects; they almost always sit in the <head>
+const refreshBodyMaxBytes = 32 * 1024
+
+var (
+	// <meta http-equiv="refresh" content="0; url=/gone">, attributes in either order
+	metaRefreshPattern = regexp.MustCompile(`(?is)<meta\s[^>]*http-equiv\s*=\s*["']?refresh["']?[^>]*>`)
+	metaContentPattern = regexp.MustCompile(`(?is)content\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	refreshURLPattern  = regexp.MustCompile(`(?i)url\s*=\s*['"]?([^'"\s;]+)`)
+
+	// window.location = "...", location.href = '...', location.replace("...")
+	jsRedirectPattern = regexp.MustCompile(`(?i)(?:window\.|document\.)?location(?:\.href)?\s*(?:=\s*|\.(?:replace|assign)\s*\(\s*)["']([^"']+)["']`)
+)
+
+// checkRefresh fetches the start of a page that answered 2xx and returns
+// the absolute target of a meta-refresh or JavaScript redirect, if any
+func checkRefresh(ctx context.Context, raw string) string {
+	cfg := currentConfig()
+	ctx, done := withCheckTimeout(withDestinationGuard(ctx), cfg.LiveTimeout, logFor(ctx, "refresh").With("url", raw))
+	defer done()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Range", "bytes=0-"+strconv.Itoa(refreshBodyMaxBytes-1))
+	req.Header.Set("Accept-Encoding", inspectAcceptEncoding)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if !isHTMLType(mediaType(resp.Header.Get("Content-Type"))) {
+		return ""
+	}
+
+	body, err := decodedBody(resp, refreshBodyMaxBytes)
+	if err != nil {
+		logFor(ctx, "live").Info("skipping body inspection", "url", raw, "error", err)
+		return ""
+	}
+	target := findRefresh(string(body))
+	if target == "" {
+		return ""
+	}
+
+	// Resolve relative targets against the page that carried them
+	base := resp.Request.URL
+	ref, err := url.Parse(target)
+	if err != nil || (ref.Host == "" && ref.Path == "") {
+		// Unparseable, or only a #fragment / ?query on the same page
+		return ""
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return ""
+	}
+	if abs.String() == base.String() {
+		// A page refreshing itself is a reload, not a redirect
+		return ""
+	}
+	logFor(ctx, "live").Info("client-side redirect", "url", raw, "target", abs.String())
+	return abs.String()
+}
+
+// findRefresh returns the raw target of the first meta-refresh, falling
+// back to a JavaScript location redirect
+func findRefresh(html string) string {
+	if tag := metaRefreshPattern.FindString(html); tag != "" {
+		if m := metaContentPattern.FindStringSubmatch(tag); m != nil {
+			content := m[1] + m[2]
+			if u := refreshURLPattern.FindStringSubmatch(content); u != nil {
+				return u[1]
+			}
+		}
+	}
+	if m := jsRedirectPattern.FindStringSubmatch(html); m != nil {
+		return m[1]
+	}
+	return ""
+}
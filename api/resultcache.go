@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scanCacheMax bounds the number of cached scans; the oldest goes first
+const scanCacheMax = 100
+
+// scanCache keeps finished scans for Config.ResultCacheTTL, so reloading,
+// paging and filtering a hot page doesn't rescan it
+var scanCache = struct {
+	sync.Mutex
+	entries map[string]cachedScan
+}{entries: make(map[string]cachedScan)}
+
+// scanCacheCounter counts scanCache lookups, see /admin/cache
+var scanCacheCounter cacheCounter
+
+type cachedScan struct {
+	at          time.Time // When the scan finished
+	results     []linkResult
+	citationMap *CitationMap
+}
+
+// cachedScanPage returns a scan of title no older than Config.ResultCacheTTL
+// if useCache is set and one exists (hit), and otherwise scans and caches
+// afresh. Entries are keyed by wiki, page and scan options.
+func cachedScanPage(ctx context.Context, title string, opts scanOptions, useCache bool) (scan cachedScan, hit bool, err error) {
+	ttl := currentConfig().ResultCacheTTL
+	key := opts.key(title)
+	if useCache && ttl > 0 {
+		scanCache.Lock()
+		c, ok := scanCache.entries[key]
+		scanCache.Unlock()
+		hit := ok && time.Since(c.at) < ttl
+		scanCacheCounter.record(hit)
+		if hit {
+			logFor(ctx, "scan").Info("using cached scan", "page", title, "age", time.Since(c.at).Round(time.Second))
+			return c, true, nil
+		}
+	}
+
+	results, citationMap, err := scanPageWith(ctx, title, opts)
+	scan = cachedScan{at: time.Now(), results: results, citationMap: citationMap}
+	if err != nil || ttl <= 0 {
+		return scan, false, err
+	}
+
+	scanCache.Lock()
+	defer scanCache.Unlock()
+	for k, c := range scanCache.entries {
+		if scan.at.Sub(c.at) >= ttl {
+			delete(scanCache.entries, k)
+		}
+	}
+	if len(scanCache.entries) >= scanCacheMax {
+		oldest, oldestAt := "", scan.at
+		for k, c := range scanCache.entries {
+			if c.at.Before(oldestAt) {
+				oldest, oldestAt = k, c.at
+			}
+		}
+		delete(scanCache.entries, oldest)
+	}
+	scanCache.entries[key] = scan
+	return scan, false, nil
+}
+
+// refreshRequested reports whether ?refresh=1 asks to bypass the result
+// cache
+func refreshRequested(q url.Values) bool {
+	refresh, _ := strconv.ParseBool(q.Get("refresh"))
+	return refresh
+}
+
+// refreshURL links to the page described by base with the cache bypassed
+func refreshURL(base url.Values) template.URL {
+	v := url.Values{"refresh": {"1"}}
+	for k, vals := range base {
+		v[k] = vals
+	}
+	return template.URL("?" + v.Encode())
+}
+
+// writeCacheHeaders lets browsers and proxies cache a rendered scan until
+// its cache entry expires, and answers a matching conditional request with
+// 304 Not Modified. It reports whether it did so, in which case nothing
+// else should be written.
+//
+// The ETag is weak: it names the scan plus the request's query and lang
+// (the UI language), not the exact bytes, which differ in the "from a scan
+// N min ago" note. When scans need credentials (ProtectScans) the response
+// is private, so shared caches never hand an authenticated scan to anyone.
+func writeCacheHeaders(w http.ResponseWriter, r *http.Request, lang string, scannedAt time.Time) bool {
+	cfg := currentConfig()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s", scannedAt.UnixNano(), r.URL.RawQuery, lang)))
+	etag := `W/"` + hex.EncodeToString(sum[:8]) + `"`
+	maxAge := int((cfg.ResultCacheTTL - time.Since(scannedAt)).Seconds())
+	scope := "public"
+	if cfg.ProtectScans && authConfigured(cfg) {
+		scope = "private"
+	}
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", scannedAt.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, max(maxAge, 0)))
+	h.Add("Vary", "Accept-Language")
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || scannedAt.Truncate(time.Second).After(ims) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header names etag, using
+// the weak comparison conditional GETs call for
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
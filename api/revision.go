@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parseOldID reads ?oldid=, a revision ID to scan instead of the current
+// version of the page; 0 means the current version
+func parseOldID(q url.Values) (int64, error) {
+	s := strings.TrimSpace(q.Get("oldid"))
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid oldid %q: want a positive revision ID", s)
+	}
+	return id, nil
+}
+
+// checkRevisionTitle rejects a revision fetched by ID that turns out to
+// belong to a page other than title
+func checkRevisionTitle(got, title string, revid int64) error {
+	if normalizeTitle(got) != normalizeTitle(title) {
+		return &apiError{msg: fmt.Sprintf("revision %d belongs to '%s', not '%s'", revid, got, strings.ReplaceAll(title, "_", " ")), status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// isWrongRevision reports whether err is checkRevisionTitle's: a client
+// error rather than an upstream failure
+func isWrongRevision(err error) bool {
+	var ae *apiError
+	return errors.As(err, &ae) && ae.status == http.StatusBadRequest
+}
+
+// revisionError names the revision in MediaWiki's "nosuchrevid" error,
+// which otherwise says nothing about what was asked for
+func revisionError(err error, title string, revid int64) error {
+	var ae *apiError
+	if errors.As(err, &ae) && ae.code == "nosuchrevid" {
+		return &apiError{msg: fmt.Sprintf("revision %d of '%s' does not exist", revid, strings.ReplaceAll(title, "_", " ")), code: ae.code, info: ae.info}
+	}
+	return err
+}
+
+// scannedRevision is the revision a scan read, or 0 if it failed before
+// fetching the page
+func scannedRevision(cm *CitationMap) int64 {
+	if cm == nil {
+		return 0
+	}
+	return cm.Revision
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// robotsTimeout bounds the robots.txt fetch so politeness never stalls a scan
+	robotsTimeout = 3 * time.Second
+	// robotsTTL is how long a fetched robots.txt is reused
+	robotsTTL = time.Hour
+	// robotsMaxBytes caps how much of a robots.txt we read
+	robotsMaxBytes = 512 * 1024
+	// robotsMaxCrawlDelay caps a site's crawl-delay so one host can't stall a scan
+	robotsMaxCrawlDelay = 30 * time.Second
+	// robotsMaxHosts bounds robotsCache; articles cite arbitrary hosts
+	robotsMaxHosts = 10000
+)
+
+// robotsRules are the directives that apply to our user agent on one host
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	fetched time.Time
+}
+
+// robotsCache holds parsed robots.txt files keyed by scheme://host
+var robotsCache = struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}{entries: make(map[string]robotsEntry)}
+
+// robotsCacheCounter counts robotsCache lookups, see /admin/cache
+var robotsCacheCounter cacheCounter
+
+// robotsAllowed reports whether raw may be fetched under the host's
+// robots.txt, and the crawl-delay the host asks for. Fetch failures and
+// missing files allow everything: for link checking we'd rather probe than
+// wrongly skip.
+func robotsAllowed(ctx context.Context, raw string, cfg Config) (bool, time.Duration) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return true, 0
+	}
+	rules := getRobots(ctx, u, cfg)
+	if rules == nil {
+		return true, 0
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allows(path), rules.crawlDelay
+}
+
+func getRobots(ctx context.Context, u *url.URL, cfg Config) *robotsRules {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	robotsCache.mu.Lock()
+	entry, ok := robotsCache.entries[key]
+	robotsCache.mu.Unlock()
+	hit := ok && time.Since(entry.fetched) < robotsTTL
+	robotsCacheCounter.record(hit)
+	if hit {
+		return entry.rules
+	}
+
+	rules := fetchRobots(ctx, key+"/robots.txt", cfg)
+
+	robotsCache.mu.Lock()
+	if _, ok := robotsCache.entries[key]; !ok && len(robotsCache.entries) >= robotsMaxHosts {
+		evictRobotsLocked()
+	}
+	robotsCache.entries[key] = robotsEntry{rules: rules, fetched: time.Now()}
+	robotsCache.mu.Unlock()
+	return rules
+}
+
+// evictRobotsLocked makes room in a full robotsCache: it drops expired
+// entries, or failing that the oldest one. Caller holds robotsCache.mu.
+func evictRobotsLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for k, e := range robotsCache.entries {
+		if time.Since(e.fetched) >= robotsTTL {
+			delete(robotsCache.entries, k)
+			continue
+		}
+		if oldestKey == "" || e.fetched.Before(oldest) {
+			oldestKey, oldest = k, e.fetched
+		}
+	}
+	if len(robotsCache.entries) >= robotsMaxHosts {
+		delete(robotsCache.entries, oldestKey)
+	}
+}
+
+func fetchRobots(ctx context.Context, robotsURL string, cfg Config) *robotsRules {
+	ctx, cancel := context.WithTimeout(ctx, robotsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logFor(ctx, "robots").Warn("fetch failed", "url", robotsURL, "error", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logFor(ctx, "robots").Info("non-OK status, allowing all", "url", robotsURL, "status_code", resp.StatusCode)
+		return nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, robotsMaxBytes), robotsAgentToken(cfg.UserAgent))
+}
+
+// robotsAgentToken extracts the product token ("IABot-Go") from a User-Agent
+func robotsAgentToken(ua string) string {
+	token := ua
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
+
+// parseRobots returns the rules of the group matching agent, falling back
+// to the "*" group. A group matches when its user-agent is our product
+// token, compared case-insensitively (RFC 9309); "bot" doesn't match
+// "iabot-go".
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	var specific, wildcard *robotsRules
+	var current []*robotsRules // groups the current lines apply to
+	inAgents := false
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			switch ua := robotsAgentToken(value); {
+			case ua == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case ua != "" && ua == agent:
+				if specific == nil {
+					specific = &robotsRules{}
+				}
+				current = append(current, specific)
+			}
+		case "allow", "disallow", "crawl-delay":
+			inAgents = false
+			for _, g := range current {
+				switch key {
+				case "allow":
+					if value != "" {
+						g.allow = append(g.allow, value)
+					}
+				case "disallow":
+					if value != "" {
+						g.disallow = append(g.disallow, value)
+					}
+				case "crawl-delay":
+					if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+						g.crawlDelay = time.Duration(secs * float64(time.Second))
+						if g.crawlDelay > robotsMaxCrawlDelay {
+							g.crawlDelay = robotsMaxCrawlDelay
+						}
+					}
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	if specific != nil {
+		return specific
+	}
+	return wildcard
+}
+
+// allows applies longest-match precedence; Allow wins ties
+func (rr *robotsRules) allows(path string) bool {
+	best, allowed := -1, true
+	for _, p := range rr.disallow {
+		if robotsMatch(p, path) && len(p) > best {
+			best, allowed = len(p), false
+		}
+	}
+	for _, p := range rr.allow {
+		if robotsMatch(p, path) && len(p) >= best {
+			best, allowed = len(p), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a robots.txt path pattern, supporting the "*"
+// wildcard and the "$" end anchor
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			// Last literal after a wildcard must end the path
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
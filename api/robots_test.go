@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsAgentToken(t *testing.T) {
+	tests := []struct{ ua, want string }{
+		{buildUserAgent(""), "iabot-go"},
+		{buildUserAgent("ops@example.org"), "iabot-go"},
+		{"IABot-Go", "iabot-go"},
+		{"Mozilla/5.0 (compatible)", "mozilla"},
+	}
+	for _, tt := range tests {
+		if got := robotsAgentToken(tt.ua); got != tt.want {
+			t.Errorf("robotsAgentToken(%q) = %q; want %q", tt.ua, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobotsGroupSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		allowed []string
+		blocked []string
+	}{
+		{
+			"our group wins over *",
+			"User-agent: *\nDisallow: /\n\nUser-agent: IABot-Go\nDisallow: /private\n",
+			[]string{"/", "/public"}, []string{"/private/x"},
+		},
+		{
+			"token matched case-insensitively",
+			"User-agent: iabot-go\nDisallow: /x\n",
+			nil, []string{"/x"},
+		},
+		{
+			"substring of our token is another bot",
+			"User-agent: bot\nDisallow: /\n\nUser-agent: go\nDisallow: /\n\nUser-agent: *\nDisallow: /private\n",
+			[]string{"/", "/public"}, []string{"/private"},
+		},
+		{
+			"longer token containing ours is another bot",
+			"User-agent: IABot-Go-Extra\nDisallow: /\n",
+			[]string{"/"}, nil,
+		},
+		{
+			"version after the token",
+			"User-agent: IABot-Go/2.0\nDisallow: /x\n",
+			nil, []string{"/x"},
+		},
+		{
+			"grouped user-agent lines",
+			"User-agent: otherbot\nUser-agent: IABot-Go\nDisallow: /shared\n\nUser-agent: *\nDisallow: /\n",
+			[]string{"/mine"}, []string{"/shared"},
+		},
+		{
+			"wildcard only",
+			"# comment\nUser-agent: *\nDisallow: /tmp # trailing comment\n",
+			[]string{"/"}, []string{"/tmp/a"},
+		},
+		{
+			"empty disallow allows everything",
+			"User-agent: *\nDisallow:\n",
+			[]string{"/", "/anything"}, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(tt.robots), "iabot-go")
+			if rules == nil {
+				if len(tt.blocked) > 0 {
+					t.Fatal("no rules apply; want some paths blocked")
+				}
+				return
+			}
+			for _, p := range tt.allowed {
+				if !rules.allows(p) {
+					t.Errorf("%s blocked; want allowed", p)
+				}
+			}
+			for _, p := range tt.blocked {
+				if rules.allows(p) {
+					t.Errorf("%s allowed; want blocked", p)
+				}
+			}
+		})
+	}
+}
+
+func TestRobotsRulesAllows(t *testing.T) {
+	rules := &robotsRules{
+		allow:    []string{"/docs/public", "/page", "/*.css$"},
+		disallow: []string{"/docs", "/page", "/*.pdf$", "/search*q=", "/exact$"},
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/docs/secret", false},
+		{"/docs/public/a", true}, // Longer Allow wins
+		{"/page", true},          // Allow wins a tie
+		{"/files/report.pdf", false},
+		{"/files/report.pdf?x=1", true}, // $ anchors the end
+		{"/theme/site.css", true},
+		{"/search?lang=en&q=foo", false},
+		{"/search?lang=en", true},
+		{"/exact", false},
+		{"/exactly", true},
+		{"/other", true},
+	}
+	for _, tt := range tests {
+		if got := rules.allows(tt.path); got != tt.want {
+			t.Errorf("allows(%q) = %v; want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobotsCrawlDelay(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{"3600", robotsMaxCrawlDelay},
+		{"-1", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader("User-agent: *\nCrawl-delay: "+tt.value+"\n"), "iabot-go")
+		if rules == nil || rules.crawlDelay != tt.want {
+			t.Errorf("Crawl-delay: %s gave %+v; want %v", tt.value, rules, tt.want)
+		}
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	const host = "http://robots-test.example"
+	forget := func() {
+		robotsCache.mu.Lock()
+		delete(robotsCache.entries, host)
+		robotsCache.mu.Unlock()
+	}
+	forget()
+	t.Cleanup(forget)
+
+	var fetches atomic.Int32
+	stubUpstream(t, testConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			t.Errorf("unexpected request %s%s", r.Host, r.URL)
+		}
+		fetches.Add(1)
+		w.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 1\n"))
+	}))
+	tests := []struct {
+		url       string
+		wantAllow bool
+	}{
+		{host + "/private/page", false},
+		{host + "/public", true},
+		{host, true},
+	}
+	for _, tt := range tests {
+		allowed, delay := robotsAllowed(context.Background(), tt.url, currentConfig())
+		if allowed != tt.wantAllow || delay != time.Second {
+			t.Errorf("robotsAllowed(%q) = %v, %v; want %v, 1s", tt.url, allowed, delay, tt.wantAllow)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times; want 1 (cached)", n)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ScanResponse is the JSON envelope for /api/scan
+type ScanResponse struct {
+	Page           string       `json:"page"`
+	Title          string       `json:"title,omitempty"`           // Title actually scanned, when MediaWiki resolved page to another one
+	RedirectedFrom string       `json:"redirected_from,omitempty"` // Redirect followed from page to Title
+	AddedSince     string       `json:"added_since,omitempty"`     // Only links added after this revision or time were scanned
+	Revision       int64        `json:"revision,omitempty"`        // Revision ID scanned: ?oldid= if given, otherwise the current one
+	Summary        ScanSummary  `json:"summary"`
+	Paging         *resultPage  `json:"paging,omitempty"` // Set when ?verdict=, ?offset= or ?limit= narrowed Results
+	Results        []linkResult `json:"results"`
+	Partial        bool         `json:"partial,omitempty"` // The scan timed out; Results hold the links checked before that and Error says how many
+	Error          *errorBody   `json:"error,omitempty"`
+}
+
+// errorBody is the JSON form of an error. For *apiError the status and
+// payload are kept separate so clients can tell, say, a MediaWiki decode
+// failure from a network error.
+type errorBody struct {
+	Message string `json:"message"`
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"` // MediaWiki error code, e.g. "missingtitle"
+	Info    string `json:"info,omitempty"` // MediaWiki's description of the error
+	Payload string `json:"payload,omitempty"`
+}
+
+func newErrorBody(err error) *errorBody {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return &errorBody{Message: ae.msg, Status: ae.status, Code: ae.code, Info: ae.info, Payload: ae.payload}
+	}
+	return &errorBody{Message: err.Error()}
+}
+
+// ScanAPIHandler handles GET /api/scan?page=xxx and returns the scan as JSON
+// (or CSV/TSV with &format=)
+func ScanAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseResultFilter(r.URL.Query(), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	paging := pagingRequested(r.URL.Query())
+	since, err := parseAddedSince(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oldid, err := parseOldID(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Paging through a scan reuses it instead of scanning again
+	opts := scanOptions{archiveOnly: archiveOnlyRequested(r), addedSince: since, oldid: oldid}
+	scan, _, err := cachedScanPage(r.Context(), page, opts, paging && !refreshRequested(r.URL.Query()))
+	results, citationMap := scan.results, scan.citationMap
+
+	if sep, ext, ok := exportSeparator(r.URL.Query().Get("format")); ok {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeResultsCSV(w, page, results, sep, ext)
+		return
+	}
+
+	_, partial := asPartialScan(err)
+	resp := ScanResponse{Page: page, AddedSince: since, Revision: scannedRevision(citationMap), Summary: summarizeResults(results, citationMap), Results: results, Partial: partial}
+	resp.Title, resp.RedirectedFrom = scannedTitle(citationMap, page)
+	if paging && (err == nil || partial) {
+		var matched int
+		resp.Results, matched = filter.apply(results)
+		resp.Paging = &resultPage{Verdict: strings.Join(filter.verdicts, ","), Complete: filter.complete, Offset: filter.offset, Limit: filter.limit, Matched: matched}
+	}
+	if resp.Results == nil {
+		resp.Results = []linkResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		resp.Error = newErrorBody(err)
+		if isPageMissing(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if isWrongRevision(err) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if !partial {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ScanArchiveRequest is the optional POST body for /api/scan-and-archive.
+// When credentials are omitted, IA_ACCESS_KEY and IA_SECRET_KEY are used.
+type ScanArchiveRequest struct {
+	AccessKey string      `json:"access_key"`
+	SecretKey string      `json:"secret_key"`
+	Options   *SPNOptions `json:"options,omitempty"`
+
+	// RecaptureStale also submits links whose only snapshot is stale
+	// (older than Config.ArchiveStaleAfter)
+	RecaptureStale bool `json:"recapture_stale,omitempty"`
+	// SkipUnchanged compares each link's content with its latest capture's
+	// CDX digest and doesn't submit it when they match (see
+	// unchangedSnapshot). Costs a CDX lookup and a GET per link.
+	SkipUnchanged bool `json:"skip_unchanged,omitempty"`
+}
+
+// ScanArchiveResponse is the combined scan + SPN submission report
+type ScanArchiveResponse struct {
+	Page    string              `json:"page"`
+	Results []scanArchiveResult `json:"results"`
+	Errors  []string            `json:"errors,omitempty"`
+}
+
+// scanArchiveResult is a linkResult annotated with its SPN submission, if any
+type scanArchiveResult struct {
+	linkResult
+	SPN *SPNJob `json:"spn,omitempty"`
+}
+
+// ScanAndArchiveHandler handles POST /api/scan-and-archive?page=xxx
+// It scans the page and submits every unarchived, reachable URL to SPN.
+// GET is refused: a followed link or a prefetch must not spend the
+// server's SPN quota.
+func ScanAndArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req ScanArchiveRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.AccessKey == "" || req.SecretKey == "" {
+		req.AccessKey = os.Getenv("IA_ACCESS_KEY")
+		req.SecretKey = os.Getenv("IA_SECRET_KEY")
+	}
+	if req.AccessKey == "" || req.SecretKey == "" {
+		http.Error(w, "Credentials required", http.StatusBadRequest)
+		return
+	}
+	if err := req.Options.validate(); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withScanID(r.Context())
+	results, _, err := scanPage(ctx, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp := ScanArchiveResponse{
+		Page:    page,
+		Results: make([]scanArchiveResult, 0, len(results)),
+	}
+
+	cfg := currentConfig()
+	limit := cfg.SPNMaxBatch
+	submitted, skipped, unchanged := 0, 0, 0
+	for _, lr := range results {
+		res := scanArchiveResult{linkResult: lr}
+		if needsArchive(lr, req.RecaptureStale) {
+			target := archiveTarget(lr, cfg)
+			if req.SkipUnchanged {
+				if job, ok := unchangedSnapshot(ctx, target); ok {
+					// Costs no quota, so it doesn't count toward the limit
+					unchanged++
+					res.SPN = &job
+					resp.Results = append(resp.Results, res)
+					continue
+				}
+			}
+			if submitted >= limit {
+				skipped++
+			} else {
+				submitted++
+				job, err := submitToSPN(ctx, target, req.AccessKey, req.SecretKey, req.Options)
+				if err != nil {
+					job = SPNJob{
+						URL:    target,
+						Status: "error",
+						Error:  err.Error(),
+					}
+				}
+				res.SPN = &job
+			}
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	if skipped > 0 {
+		resp.Errors = append(resp.Errors,
+			fmt.Sprintf("%d unarchived URLs not submitted (batch limit %d)", skipped, limit))
+	}
+	logFor(ctx, "scan_archive").Info("submitted unarchived links", "page", page, "submitted", submitted, "skipped", skipped, "unchanged", unchanged)
+
+	if sep, ext, ok := exportSeparator(r.URL.Query().Get("format")); ok {
+		rows := make([]linkResult, 0, len(resp.Results))
+		for _, res := range resp.Results {
+			rows = append(rows, res.linkResult)
+		}
+		writeResultsCSV(w, page, rows, sep, ext)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// needsArchive reports whether a scanned link should be submitted to SPN:
+// it has no archive, or only a stale one when recaptureStale is set.
+// Links that failed at the network level (LiveCode 0: DNS, TLS, refused...)
+// are skipped since SPN can't capture them either, and so are complete
+// links, whose citation already carries an archive.
+func needsArchive(lr linkResult, recaptureStale bool) bool {
+	if lr.LiveCode == 0 || lr.Complete {
+		return false
+	}
+	return !lr.Archived || (recaptureStale && lr.ArchiveStale)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultShortenerHosts are URL shorteners whose destination is reported
+// unless Config.ShortenerHosts overrides them
+var defaultShortenerHosts = []string{
+	"bit.ly",
+	"t.co",
+	"tinyurl.com",
+	"goo.gl",
+	"ow.ly",
+	"buff.ly",
+	"is.gd",
+	"dlvr.it",
+	"trib.al",
+	"fb.me",
+	"lnkd.in",
+	"amzn.to",
+	"youtu.be",
+}
+
+// isShortenerURL reports whether u is on one of hosts (or a subdomain)
+func isShortenerURL(u string, hosts []string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	for _, h := range hosts {
+		if hostMatches(host, strings.ToLower(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedURL returns where a shortener link landed after redirects, or ""
+// if u isn't a shortener or didn't go anywhere else
+func resolvedURL(u, finalURL string, hosts []string) string {
+	if finalURL == "" || finalURL == u || !isShortenerURL(u, hosts) {
+		return ""
+	}
+	return finalURL
+}
+
+// archiveTarget is the URL to look up or save in the archives for lr: its
+// resolved destination with Config.ArchiveResolvedURL, otherwise the URL
+// as cited
+func archiveTarget(lr linkResult, cfg Config) string {
+	if cfg.ArchiveResolvedURL && lr.ResolvedURL != "" {
+		return lr.ResolvedURL
+	}
+	return lr.URL
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAddedSince reads ?added_since=, which restricts a scan to links
+// added after a baseline: a revision ID, or a date (YYYY-MM-DD or RFC3339)
+// meaning the revision current at that time. Dates come back as RFC3339
+// UTC; empty means a full scan.
+func parseAddedSince(q url.Values) (string, error) {
+	s := strings.TrimSpace(q.Get("added_since"))
+	if s == "" {
+		return "", nil
+	}
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if id <= 0 {
+			return "", fmt.Errorf("invalid added_since %q: revision IDs are positive", s)
+		}
+		return s, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if t.After(time.Now()) {
+				return "", fmt.Errorf("invalid added_since %q: in the future", s)
+			}
+			return t.UTC().Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("invalid added_since %q: want a revision ID or a date (YYYY-MM-DD or RFC3339)", s)
+}
+
+// baselineRevision resolves an added_since value to a revision of title.
+// A date picks the last revision at or before it; 0 means the page didn't
+// exist yet, so every link counts as added.
+func baselineRevision(ctx context.Context, title, since string) (int64, error) {
+	if id, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return id, nil
+	}
+
+	v := url.Values{}
+	v.Set("action", "query")
+	v.Set("prop", "revisions")
+	v.Set("titles", title)
+	v.Set("rvprop", "ids|timestamp")
+	v.Set("rvlimit", "1")
+	v.Set("rvdir", "older")
+	v.Set("rvstart", since)
+	v.Set("formatversion", "2")
+
+	var parsed struct {
+		Query struct {
+			Pages []struct {
+				Missing   bool `json:"missing"`
+				Revisions []struct {
+					RevID int64 `json:"revid"`
+				} `json:"revisions"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	// Only the first revision matters, so don't follow rvcontinue
+	var revid int64
+	err := fetchMediaWiki(ctx, v, func(body []byte, status int) error {
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return &apiError{msg: "mediawiki api decode", status: status}
+		}
+		for _, p := range parsed.Query.Pages {
+			if p.Missing {
+				return &apiError{msg: mediaWikiErrorMessage("missingtitle", "", title), code: "missingtitle"}
+			}
+			if len(p.Revisions) > 0 {
+				revid = p.Revisions[0].RevID
+			}
+		}
+		return errStopPaging
+	})
+	return revid, err
+}
+
+// baselineURLs returns the URLs cited in the given revision of title, as a
+// set. The revision has to belong to title, otherwise the comparison would
+// be meaningless.
+func baselineURLs(ctx context.Context, title string, revid int64) (map[string]bool, error) {
+	urls := make(map[string]bool)
+	if revid == 0 {
+		return urls, nil
+	}
+
+	v := url.Values{}
+	v.Set("action", "parse")
+	v.Set("oldid", strconv.FormatInt(revid, 10))
+	v.Set("prop", "wikitext")
+
+	var parsed struct {
+		Parse struct {
+			Title    string `json:"title"`
+			Wikitext struct {
+				Content string `json:"*"`
+			} `json:"wikitext"`
+		} `json:"parse"`
+	}
+	err := fetchMediaWiki(ctx, v, func(body []byte, status int) error {
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return &apiError{msg: "mediawiki api decode", status: status}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, revisionError(err, title, revid)
+	}
+	if err := checkRevisionTitle(parsed.Parse.Title, title, revid); err != nil {
+		return nil, err
+	}
+	for _, u := range ParseCitations(parsed.Parse.Wikitext.Content).GetUniqueURLs() {
+		urls[u] = true
+	}
+	return urls, nil
+}
+
+// normalizeTitle compares titles the way MediaWiki does, ignoring the
+// underscore/space difference and the case of the first letter
+func normalizeTitle(t string) string {
+	t = strings.TrimSpace(strings.ReplaceAll(t, "_", " "))
+	if t == "" {
+		return t
+	}
+	r := []rune(t)
+	return strings.ToUpper(string(r[0])) + string(r[1:])
+}
+
+// filterAdded keeps the URLs of urls not in baseline
+func filterAdded(urls []string, baseline map[string]bool) []string {
+	var added []string
+	for _, u := range urls {
+		if !baseline[u] {
+			added = append(added, u)
+		}
+	}
+	return added
+}
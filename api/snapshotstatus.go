@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// What Wayback lookups do with a snapshot whose captured status the
+// availability API leaves empty or reports as "-", selected with
+// Config.UnknownSnapshotStatus
+const (
+	UnknownSnapshotStatusCDX    = "cdx"    // Ask the CDX API for the status; accept if it doesn't know either. The default
+	UnknownSnapshotStatusAccept = "accept" // Accept the snapshot with status "status unknown"
+	UnknownSnapshotStatusReject = "reject" // Treat it like a bad status
+)
+
+// snapshotStatusUnknown is the Status of a snapshot accepted without a
+// known captured status
+const snapshotStatusUnknown = "status unknown"
+
+// ParseUnknownSnapshotStatus validates a Config.UnknownSnapshotStatus
+// value; empty means UnknownSnapshotStatusCDX
+func ParseUnknownSnapshotStatus(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return UnknownSnapshotStatusCDX, nil
+	case UnknownSnapshotStatusCDX, UnknownSnapshotStatusAccept, UnknownSnapshotStatusReject:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown policy %q (want %q, %q or %q)", s, UnknownSnapshotStatusCDX, UnknownSnapshotStatusAccept, UnknownSnapshotStatusReject)
+}
+
+// snapshotStatusCode parses a captured status as the availability and CDX
+// APIs report it. ok is false for "", "-" and anything else that isn't an
+// HTTP status.
+func snapshotStatusCode(s string) (code int, ok bool) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return 0, false
+	}
+	return code, true
+}
+
+// cdxSnapshotStatus asks the Wayback CDX API for the captured status of
+// the capture of raw at timestamp (YYYYMMDDhhmmss). ok is false when the
+// CDX index doesn't know it either.
+func cdxSnapshotStatus(ctx context.Context, raw, timestamp string) (code int, ok bool, err error) {
+	v := url.Values{}
+	v.Set("url", raw)
+	v.Set("from", timestamp)
+	v.Set("to", timestamp)
+	v.Set("output", "json")
+	v.Set("fl", "statuscode")
+	v.Set("limit", "1")
+	cfg := currentConfig()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://web.archive.org/cdx/search/cdx?"+v.Encode(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("cdx: HTTP %s", resp.Status)
+	}
+	b, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// A header row, then one row per capture: [["statuscode"],["200"]].
+	// No captures gives an empty body rather than "[]".
+	var rows [][]string
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return 0, false, nil
+	}
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return 0, false, fmt.Errorf("cdx: %w", err)
+	}
+	if len(rows) < 2 || len(rows[1]) == 0 {
+		return 0, false, nil
+	}
+	code, ok = snapshotStatusCode(rows[1][0])
+	return code, ok, nil
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLookupNearUnknownSnapshotStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		status     string // Availability API snapshot status
+		cdxCode    int    // CDX API response code
+		cdxBody    string
+		wantFound  bool
+		wantStatus string
+		wantCDX    bool
+	}{
+		{"cdx knows 200", UnknownSnapshotStatusCDX, "", 200, `[["statuscode"],["200"]]`, true, "200", true},
+		{"cdx knows 404", UnknownSnapshotStatusCDX, "-", 200, `[["statuscode"],["404"]]`, false, "snapshot has bad status: 404", true},
+		{"cdx has no capture", UnknownSnapshotStatusCDX, "", 200, "", true, snapshotStatusUnknown, true},
+		{"cdx has no status", UnknownSnapshotStatusCDX, "", 200, `[["statuscode"],["-"]]`, true, snapshotStatusUnknown, true},
+		{"cdx fails", UnknownSnapshotStatusCDX, "", 503, "", true, snapshotStatusUnknown, true},
+		{"accept", UnknownSnapshotStatusAccept, "", 0, "", true, snapshotStatusUnknown, false},
+		{"reject", UnknownSnapshotStatusReject, "-", 0, "", false, "snapshot has bad status: -", false},
+		{"known status", UnknownSnapshotStatusCDX, "200", 0, "", true, "200", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cdxCalls atomic.Int32
+			cfg := testConfig()
+			cfg.UnknownSnapshotStatus = tt.policy
+			stubUpstream(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/wayback/available":
+					w.Write([]byte(waybackResponse(tt.status)))
+				case "/cdx/search/cdx":
+					cdxCalls.Add(1)
+					if q := r.URL.Query(); q.Get("url") != "http://example.com/" || q.Get("from") != "20200101000000" {
+						t.Errorf("unexpected CDX query %s", r.URL.RawQuery)
+					}
+					w.WriteHeader(tt.cdxCode)
+					w.Write([]byte(tt.cdxBody))
+				default:
+					t.Errorf("unexpected request %s%s", r.Host, r.URL)
+				}
+			}))
+
+			snap, err := WaybackProvider{}.LookupNear(context.Background(), "http://example.com/", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snap.Found != tt.wantFound || snap.Status != tt.wantStatus {
+				t.Errorf("LookupNear = found %v, status %q; want %v, %q", snap.Found, snap.Status, tt.wantFound, tt.wantStatus)
+			}
+			if called := cdxCalls.Load() > 0; called != tt.wantCDX {
+				t.Errorf("CDX called = %v; want %v", called, tt.wantCDX)
+			}
+		})
+	}
+}
+
+func TestParseUnknownSnapshotStatus(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{"", UnknownSnapshotStatusCDX, false},
+		{"cdx", UnknownSnapshotStatusCDX, false},
+		{" Accept ", UnknownSnapshotStatusAccept, false},
+		{"REJECT", UnknownSnapshotStatusReject, false},
+		{"ignore", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnknownSnapshotStatus(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseUnknownSnapshotStatus(%q) = %q, %v; want %q, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -3,9 +3,9 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,53 +15,207 @@ import (
 
 // SPNJob represents a pending or completed archive request
 type SPNJob struct {
-	URL       string `json:"url"`
-	JobID     string `json:"job_id"`
-	Status    string `json:"status"` // "pending", "success", "error"
-	Timestamp string `json:"timestamp,omitempty"`
-	Error     string `json:"error,omitempty"`
+	URL        string `json:"url"`
+	JobID      string `json:"job_id"`
+	Status     string `json:"status"` // "queued", "submitting", "pending", "success", "error", "already-archived", "unchanged"
+	Timestamp  string `json:"timestamp,omitempty"`
+	ArchiveURL string `json:"archive_url,omitempty"` // Existing snapshot when "already-archived" or "unchanged"; the new capture after a waited-for "success"
+	Error      string `json:"error,omitempty"`
+	Resources  int    `json:"resources,omitempty"` // Resources captured so far, from the status API
+
+	// Local queue tracking. Position and ETA are only set while "queued".
+	QueueID        string `json:"queue_id,omitempty"`
+	QueuePosition  int    `json:"queue_position,omitempty"`  // 1 = next to be submitted
+	EstimatedReady string `json:"estimated_ready,omitempty"` // RFC3339 estimate of when it will be submitted
+}
+
+// spnTerminalStatuses are job statuses that will never change again
+var spnTerminalStatuses = map[string]bool{
+	"success":          true,
+	"error":            true,
+	"already-archived": true,
+	"unchanged":        true, // Not submitted: the page matches its latest capture
+}
+
+// IsTerminal reports whether a job in this status is finished, so callers
+// know when to stop polling. Unknown and empty statuses are not terminal.
+func IsTerminal(status string) bool {
+	return spnTerminalStatuses[status]
 }
 
 // SPNSubmitRequest is the request body for submitting URLs
 type SPNSubmitRequest struct {
-	URLs      []string `json:"urls"`
-	AccessKey string   `json:"access_key"`
-	SecretKey string   `json:"secret_key"`
+	URLs      []string    `json:"urls"`
+	AccessKey string      `json:"access_key"`
+	SecretKey string      `json:"secret_key"`
+	Options   *SPNOptions `json:"options,omitempty"`
+	DryRun    bool        `json:"dry_run,omitempty"` // Validate credentials and URLs without capturing
+	Force     bool        `json:"force,omitempty"`   // Capture even if a recent snapshot exists
+	Strict    bool        `json:"strict,omitempty"`  // Reject batches over the limit instead of dropping the excess
+	Wait      bool        `json:"wait,omitempty"`    // Single URL only: capture synchronously and return the finished job
+}
+
+// SPNOptions exposes optional Save Page Now capture flags.
+// A nil *SPNOptions keeps the default capture (capture_all=1 only).
+type SPNOptions struct {
+	CaptureOutlinks   bool `json:"capture_outlinks,omitempty"`   // Also capture pages linked from the target
+	CaptureScreenshot bool `json:"capture_screenshot,omitempty"` // Store a PNG screenshot alongside the capture
+	SkipFirstArchive  bool `json:"skip_first_archive,omitempty"` // Don't check whether this is the first capture (faster)
+	ForceGet          bool `json:"force_get,omitempty"`          // Use a plain HTTP GET instead of a headless browser
+}
+
+// validate rejects option combinations SPN can't honor
+func (o *SPNOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	// Screenshots and outlink extraction both need the headless browser,
+	// which force_get bypasses
+	if o.ForceGet && o.CaptureScreenshot {
+		return fmt.Errorf("capture_screenshot cannot be combined with force_get")
+	}
+	if o.ForceGet && o.CaptureOutlinks {
+		return fmt.Errorf("capture_outlinks cannot be combined with force_get")
+	}
+	return nil
+}
+
+// apply sets the form fields for the enabled options
+func (o *SPNOptions) apply(form url.Values) {
+	if o == nil {
+		return
+	}
+	if o.CaptureOutlinks {
+		form.Set("capture_outlinks", "1")
+	}
+	if o.CaptureScreenshot {
+		form.Set("capture_screenshot", "1")
+	}
+	if o.SkipFirstArchive {
+		form.Set("skip_first_archive", "1")
+	}
+	if o.ForceGet {
+		form.Set("force_get", "1")
+	}
 }
 
 // SPNSubmitResponse is the response for a submission
 type SPNSubmitResponse struct {
-	Submitted []SPNJob `json:"submitted"`
-	Errors    []string `json:"errors,omitempty"`
+	Submitted  []SPNJob `json:"submitted"`
+	Dropped    []string `json:"dropped,omitempty"` // URLs over the batch limit, not queued
+	BatchLimit int      `json:"batch_limit"`       // Most URLs accepted per request (Config.SPNMaxBatch)
+	Errors     []string `json:"errors,omitempty"`
 }
 
-// Rate limiter for SPN API (10 seconds between requests = 6/min)
+const (
+	// spnMaxInterval caps how far the SPN limiter backs off after 429s
+	spnMaxInterval = 5 * time.Minute
+	// spnRelaxAfter is how many successful submissions in a row halve a
+	// backed-off interval again
+	spnRelaxAfter = 3
+)
+
+// Rate limiter for SPN API (Config.SPNInterval between requests, 10 seconds
+// = 6/min by default). Callers reserve the next free slot and then sleep
+// until it arrives, so the lock is never held while waiting and nextSlot can
+// be read at any time.
+//
+// The spacing adapts: each 429 from SPN doubles it (up to spnMaxInterval)
+// and holds off the next slot for any Retry-After, and every spnRelaxAfter
+// successes in a row halve it again. It never drops below
+// Config.SPNInterval, which is the policy limit.
 type spnRateLimiter struct {
 	mu          sync.Mutex
-	lastRequest time.Time
-	minInterval time.Duration
+	lastRequest time.Time     // Most recently reserved slot (may be in the future)
+	backoff     time.Duration // Backed-off spacing; 0 when at the base interval
+	successes   int           // Successful submissions since the last change
 }
 
-var spnLimiter = &spnRateLimiter{minInterval: 10 * time.Second}
+var spnLimiter = &spnRateLimiter{}
 
 func (rl *spnRateLimiter) wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() { rateLimitWaitSeconds.WithLabelValues("spn").Observe(time.Since(start).Seconds()) }()
+
+	interval := rl.interval()
 	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	slot := rl.lastRequest.Add(interval)
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	rl.lastRequest = slot
+	rl.mu.Unlock()
 
-	elapsed := time.Since(rl.lastRequest)
-	if elapsed < rl.minInterval {
-		wait := rl.minInterval - elapsed
+	if wait := time.Until(slot); wait > 0 {
 		select {
 		case <-time.After(wait):
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
-	rl.lastRequest = time.Now()
 	return nil
 }
 
+// nextSlot returns when the next request could start
+func (rl *spnRateLimiter) nextSlot() time.Time {
+	interval := rl.interval()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	slot := rl.lastRequest.Add(interval)
+	if now := time.Now(); slot.Before(now) {
+		return now
+	}
+	return slot
+}
+
+// interval returns the current spacing between requests
+func (rl *spnRateLimiter) interval() time.Duration {
+	base := currentConfig().SPNInterval
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return max(base, rl.backoff)
+}
+
+// throttled backs off after SPN answered 429, pushing the next slot out by
+// at least retryAfter
+func (rl *spnRateLimiter) throttled(retryAfter time.Duration) {
+	base := currentConfig().SPNInterval
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.backoff = min(max(base, rl.backoff)*2, max(base, spnMaxInterval))
+	rl.successes = 0
+	if hold := time.Now().Add(retryAfter); rl.lastRequest.Add(rl.backoff).Before(hold) {
+		rl.lastRequest = hold.Add(-rl.backoff)
+	}
+	spnIntervalSeconds.Set(rl.backoff.Seconds())
+	logFor(context.Background(), "spn").Warn("throttled by SPN, backing off",
+		"interval", rl.backoff, "retry_after", retryAfter)
+}
+
+// succeeded relaxes a backed-off interval after enough successes in a row
+func (rl *spnRateLimiter) succeeded() {
+	base := currentConfig().SPNInterval
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.backoff <= base {
+		rl.backoff, rl.successes = 0, 0
+		spnIntervalSeconds.Set(base.Seconds())
+		return
+	}
+	if rl.successes++; rl.successes < spnRelaxAfter {
+		return
+	}
+	rl.successes = 0
+	if rl.backoff /= 2; rl.backoff <= base {
+		rl.backoff = 0
+	}
+	spnIntervalSeconds.Set(max(base, rl.backoff).Seconds())
+	logFor(context.Background(), "spn").Info("relaxing SPN interval", "interval", max(base, rl.backoff))
+}
+
 // SPNSubmitHandler handles POST /api/spn/submit
+// URLs are queued and submitted in the background at the SPN rate limit;
+// the response returns immediately with each job's queue position.
 func SPNSubmitHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -85,29 +239,84 @@ func SPNSubmitHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Limit batch size
-	if len(req.URLs) > 10 {
-		req.URLs = req.URLs[:10]
+	if err := req.Options.validate(); err != nil {
+		http.Error(w, "Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Limit batch size; the excess is rejected or reported, never silently lost
+	limit := currentConfig().SPNMaxBatch
+	if len(req.URLs) > limit && req.Strict {
+		http.Error(w, fmt.Sprintf("Too many URLs: %d (batch limit %d)", len(req.URLs), limit), http.StatusBadRequest)
+		return
 	}
 
 	resp := SPNSubmitResponse{
-		Submitted: make([]SPNJob, 0, len(req.URLs)),
+		Submitted:  make([]SPNJob, 0, min(len(req.URLs), limit)),
+		BatchLimit: limit,
+	}
+	if len(req.URLs) > limit {
+		resp.Dropped = req.URLs[limit:]
+		req.URLs = req.URLs[:limit]
+		resp.Errors = append(resp.Errors,
+			fmt.Sprintf("%d URLs not submitted (batch limit %d)", len(resp.Dropped), limit))
+	}
+
+	// Dry run: check credentials and URLs, but never call /save or touch
+	// the rate limiter
+	if req.DryRun {
+		if err := checkSPNCredentials(r.Context(), req.AccessKey, req.SecretKey); err != nil {
+			resp.Errors = append(resp.Errors, err.Error())
+		}
+		for _, targetURL := range req.URLs {
+			job := SPNJob{URL: targetURL, Status: "would-submit"}
+			if !isAbsoluteHTTPURL(targetURL) {
+				job.Status = "error"
+				job.Error = "not an absolute http(s) URL"
+			}
+			resp.Submitted = append(resp.Submitted, job)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Synchronous single-URL capture: skip the queue and report the result
+	if req.Wait {
+		if len(req.URLs) != 1 {
+			http.Error(w, "wait requires exactly one URL", http.StatusBadRequest)
+			return
+		}
+		targetURL := req.URLs[0]
+		job, ok := SPNJob{}, false
+		if !req.Force {
+			job, ok = recentSnapshot(r.Context(), targetURL)
+		}
+		if !ok {
+			var err error
+			if job, err = SubmitAndWait(r.Context(), targetURL, req.AccessKey, req.SecretKey, req.Options); err != nil {
+				resp.Errors = append(resp.Errors, err.Error())
+			}
+		}
+		resp.Submitted = append(resp.Submitted, job)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
 	}
 
 	// Submit each URL
 	for _, targetURL := range req.URLs {
-		job, err := submitToSPN(r.Context(), targetURL, req.AccessKey, req.SecretKey)
-		if err != nil {
-			job = SPNJob{
-				URL:    targetURL,
-				Status: "error",
-				Error:  err.Error(),
+		if !req.Force {
+			if job, ok := recentSnapshot(r.Context(), targetURL); ok {
+				resp.Submitted = append(resp.Submitted, job)
+				continue
 			}
 		}
-		resp.Submitted = append(resp.Submitted, job)
+		resp.Submitted = append(resp.Submitted, spnJobs.enqueue(targetURL, req.AccessKey, req.SecretKey, req.Options))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(resp)
 }
 
@@ -135,8 +344,15 @@ func SPNStatusHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // submitToSPN submits a URL to the Wayback Machine's Save Page Now API
-func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (SPNJob, error) {
-	job := SPNJob{URL: targetURL}
+func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string, opts *SPNOptions) (job SPNJob, err error) {
+	job = SPNJob{URL: targetURL}
+	defer func() {
+		status := job.Status
+		if err != nil {
+			status = "error"
+		}
+		spnSubmissionsTotal.WithLabelValues(status).Inc()
+	}()
 
 	// Wait for rate limiter
 	if err := spnLimiter.wait(ctx); err != nil {
@@ -147,11 +363,14 @@ func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (S
 	form := url.Values{}
 	form.Set("url", targetURL)
 	form.Set("capture_all", "1") // Capture even error pages
+	opts.apply(form)
 
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	cfg := currentConfig()
+	ctx, cancel := context.WithTimeout(ctx, cfg.SPNTimeout)
 	defer cancel()
 
-	log.Printf("[SPN] Submitting URL: %s", targetURL)
+	logger := logFor(ctx, "spn").With("url", targetURL)
+	logger.Info("submitting")
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
 		"https://web.archive.org/save", strings.NewReader(form.Encode()))
@@ -162,20 +381,25 @@ func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (S
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("LOW %s:%s", accessKey, secretKey))
-	req.Header.Set("User-Agent", "IABot-Go/0.1 (+https://github.com/comaeclipse/IABot-Go)")
+	req.Header.Set("User-Agent", cfg.UserAgent)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("[SPN] Request failed for %s: %v", targetURL, err)
+		logger.Warn("request failed", "error", err)
 		return job, err
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("[SPN] Response status: %d, body: %s", resp.StatusCode, string(body))
+	body, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		logger.Warn("reading response failed", "error", err)
+		return job, err
+	}
+	logger.Debug("response", "status_code", resp.StatusCode, "body", string(body))
 
-	// Handle rate limiting
+	// Handle rate limiting: slow every later submission down too
 	if resp.StatusCode == 429 {
+		spnLimiter.throttled(retryAfter(resp.Header.Get("Retry-After"), 0, spnMaxInterval))
 		return job, fmt.Errorf("rate limited, try again later")
 	}
 
@@ -187,6 +411,7 @@ func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (S
 	if resp.StatusCode != http.StatusOK {
 		return job, fmt.Errorf("SPN error: HTTP %d", resp.StatusCode)
 	}
+	spnLimiter.succeeded()
 
 	// Parse response
 	var spnResp struct {
@@ -198,7 +423,7 @@ func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (S
 	}
 	if err := json.Unmarshal(body, &spnResp); err != nil {
 		// Sometimes SPN returns HTML or non-JSON on success
-		log.Printf("[SPN] JSON decode error, treating as pending: %v", err)
+		logger.Warn("JSON decode error, treating as pending", "error", err)
 		job.Status = "pending"
 		return job, nil
 	}
@@ -217,40 +442,157 @@ func submitToSPN(ctx context.Context, targetURL, accessKey, secretKey string) (S
 		job.Status = "pending"
 	}
 
-	log.Printf("[SPN] Submitted %s: job_id=%s, status=%s", targetURL, job.JobID, job.Status)
+	logger.Info("submitted", "job_id", job.JobID, "status", job.Status)
 	return job, nil
 }
 
+// errCaptureTimeout is returned by SubmitAndWait when the capture is still
+// running at Config.SPNMaxWait
+var errCaptureTimeout = errors.New("capture did not finish in time")
+
+// SubmitAndWait submits targetURL to Save Page Now (waiting its turn at the
+// rate limiter) and then polls the job until it finishes or Config.SPNMaxWait
+// runs out. A successful job carries the capture's ArchiveURL and Timestamp.
+// On timeout the job is returned in its last known state together with
+// errCaptureTimeout; the capture may still complete later.
+func SubmitAndWait(ctx context.Context, targetURL, accessKey, secretKey string, opts *SPNOptions) (SPNJob, error) {
+	maxWait := currentConfig().SPNMaxWait
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	job, err := submitToSPN(ctx, targetURL, accessKey, secretKey, opts)
+	if err != nil {
+		job.Status = "error"
+		job.Error = err.Error()
+		return job, err
+	}
+	if job.JobID == "" || IsTerminal(job.Status) {
+		return withCaptureURL(job), nil
+	}
+
+	logger := logFor(ctx, "spn").With("url", targetURL, "job_id", job.JobID)
+	ticker := time.NewTicker(spnPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Warn("gave up waiting for capture", "after", maxWait)
+			return job, fmt.Errorf("%w (waited %s; poll job %s)", errCaptureTimeout, maxWait, job.JobID)
+		case <-ticker.C:
+		}
+
+		status, err := checkSPNStatus(ctx, job.JobID)
+		if err != nil {
+			logger.Warn("poll failed", "error", err)
+			continue
+		}
+		job.Status = status.Status
+		job.Timestamp = status.Timestamp
+		job.Resources = status.Resources
+		job.Error = status.Error
+		if IsTerminal(job.Status) {
+			logger.Info("capture finished", "status", job.Status)
+			return withCaptureURL(job), nil
+		}
+	}
+}
+
+// withCaptureURL fills in the snapshot URL of a successful capture
+func withCaptureURL(job SPNJob) SPNJob {
+	if job.Status == "success" && job.Timestamp != "" && job.ArchiveURL == "" {
+		job.ArchiveURL = waybackFlavorURL("https://web.archive.org/web/"+job.Timestamp+"/"+job.URL, currentConfig().ArchiveURLFlavor)
+	}
+	return job
+}
+
+// recentSnapshot reports an "already-archived" job when the Wayback Machine
+// holds a snapshot newer than Config.SPNSkipIfArchivedWithin
+func recentSnapshot(ctx context.Context, targetURL string) (SPNJob, bool) {
+	cfg := currentConfig()
+	if cfg.SPNSkipIfArchivedWithin <= 0 {
+		return SPNJob{}, false
+	}
+	wb := checkArchives(ctx, targetURL)
+	if !wb.Archived || wb.Source != "wayback" || time.Since(wb.Timestamp) > cfg.SPNSkipIfArchivedWithin {
+		return SPNJob{}, false
+	}
+	logFor(ctx, "spn").Info("skipping: recently archived", "url", targetURL, "timestamp", wb.Timestamp.Format(time.RFC3339))
+	return SPNJob{
+		URL:        targetURL,
+		Status:     "already-archived",
+		Timestamp:  wb.Timestamp.Format("20060102150405"),
+		ArchiveURL: wb.URL,
+	}, true
+}
+
+// checkSPNCredentials verifies the S3 keys against SPN's user status
+// endpoint, which is authenticated but doesn't start a capture
+func checkSPNCredentials(ctx context.Context, accessKey, secretKey string) error {
+	cfg := currentConfig()
+	ctx, cancel := context.WithTimeout(ctx, cfg.SPNStatusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://web.archive.org/save/status/user", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("LOW %s:%s", accessKey, secretKey))
+	req.Header.Set("User-Agent", cfg.UserAgent)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("credential check failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	logFor(ctx, "spn").Info("credential check", "status_code", resp.StatusCode)
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return fmt.Errorf("invalid credentials")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("credential check failed: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // checkSPNStatus checks the status of a SPN job
 func checkSPNStatus(ctx context.Context, jobID string) (SPNJob, error) {
 	var job SPNJob
 	job.JobID = jobID
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	cfg := currentConfig()
+	ctx, cancel := context.WithTimeout(ctx, cfg.SPNStatusTimeout)
 	defer cancel()
 
 	reqURL := "https://web.archive.org/save/status/" + url.PathEscape(jobID)
-	log.Printf("[SPN] Checking status: %s", reqURL)
+	logger := logFor(ctx, "spn").With("job_id", jobID)
+	logger.Debug("checking status", "url", reqURL)
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "IABot-Go/0.1 (+https://github.com/comaeclipse/IABot-Go)")
+	req.Header.Set("User-Agent", cfg.UserAgent)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return job, err
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("[SPN] Status response: %d, body: %s", resp.StatusCode, string(body))
+	body, err := readBody(resp.Body, cfg.MaxResponseBytes)
+	if err != nil {
+		return job, err
+	}
+	logger.Debug("status response", "status_code", resp.StatusCode, "body", string(body))
 
 	var statusResp struct {
-		Status      string `json:"status"`
-		Timestamp   string `json:"timestamp"`
-		OriginalURL string `json:"original_url"`
-		Message     string `json:"message"`
-		JobID       string `json:"job_id"`
+		Status      string   `json:"status"`
+		Timestamp   string   `json:"timestamp"`
+		OriginalURL string   `json:"original_url"`
+		Message     string   `json:"message"`
+		JobID       string   `json:"job_id"`
+		Resources   []string `json:"resources"`
 	}
 	if err := json.Unmarshal(body, &statusResp); err != nil {
 		return job, fmt.Errorf("invalid response from SPN")
@@ -259,6 +601,12 @@ func checkSPNStatus(ctx context.Context, jobID string) (SPNJob, error) {
 	job.URL = statusResp.OriginalURL
 	job.Status = statusResp.Status
 	job.Timestamp = statusResp.Timestamp
+	job.Resources = len(statusResp.Resources)
+
+	// SPN sometimes answers a still-running job without a status field
+	if job.Status == "" {
+		job.Status = "pending"
+	}
 
 	if job.Status == "error" {
 		job.Error = statusResp.Message
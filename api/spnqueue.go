@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// spnJobRetention is how long finished jobs stay queryable
+	spnJobRetention = time.Hour
+	// spnPollInterval is how often the background poller checks SPN job status
+	spnPollInterval = 5 * time.Second
+	// spnPollTimeout is how long the poller follows one job before giving up
+	spnPollTimeout = 10 * time.Minute
+	// spnMaxJobs bounds the job store; only finished jobs are ever evicted
+	spnMaxJobs = 1000
+)
+
+// spnQueueItem is a submission waiting for its rate-limiter slot
+type spnQueueItem struct {
+	id        string
+	url       string
+	accessKey string
+	secretKey string
+	opts      *SPNOptions
+}
+
+// spnQueue submits queued URLs one at a time through spnLimiter and keeps
+// every job's latest state so clients can poll it
+type spnQueue struct {
+	mu       sync.Mutex
+	pending  []*spnQueueItem
+	jobs     map[string]*SPNJob
+	finished map[string]time.Time
+	wake     chan struct{}
+	start    sync.Once
+}
+
+var spnJobs = &spnQueue{
+	jobs:     make(map[string]*SPNJob),
+	finished: make(map[string]time.Time),
+	wake:     make(chan struct{}, 1),
+}
+
+// enqueue adds a URL to the queue and returns its job with position and ETA
+func (q *spnQueue) enqueue(targetURL, accessKey, secretKey string, opts *SPNOptions) SPNJob {
+	q.start.Do(func() { go q.run() })
+
+	item := &spnQueueItem{
+		id:        newQueueID(),
+		url:       targetURL,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		opts:      opts,
+	}
+
+	q.mu.Lock()
+	q.evictLocked()
+	q.pending = append(q.pending, item)
+	q.jobs[item.id] = &SPNJob{URL: targetURL, Status: "queued", QueueID: item.id}
+	spnQueueDepth.Set(float64(len(q.pending)))
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	job, _ := q.get(item.id)
+	logFor(context.Background(), "spn").Info("queued", "url", targetURL, "queue_id", item.id, "position", job.QueuePosition)
+	return job
+}
+
+// get returns a job's current state, with position and ETA recomputed for
+// queued jobs
+func (q *spnQueue) get(id string) (SPNJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return SPNJob{}, false
+	}
+	out := *job
+	if out.Status == "queued" {
+		next, interval := spnLimiter.nextSlot(), spnLimiter.interval()
+		for i, item := range q.pending {
+			if item.id == id {
+				out.QueuePosition = i + 1
+				out.EstimatedReady = next.Add(time.Duration(i) * interval).UTC().Format(time.RFC3339)
+				break
+			}
+		}
+	}
+	return out, true
+}
+
+// list returns every tracked job
+func (q *spnQueue) list() []SPNJob {
+	q.mu.Lock()
+	ids := make([]string, 0, len(q.jobs))
+	for id := range q.jobs {
+		ids = append(ids, id)
+	}
+	q.mu.Unlock()
+
+	jobs := make([]SPNJob, 0, len(ids))
+	for _, id := range ids {
+		if job, ok := q.get(id); ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// depth returns how many submissions are waiting
+func (q *spnQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// run is the single background submitter
+func (q *spnQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			<-q.wake
+			continue
+		}
+		item := q.pending[0]
+		q.pending = q.pending[1:]
+		q.jobs[item.id].Status = "submitting"
+		spnQueueDepth.Set(float64(len(q.pending)))
+		q.mu.Unlock()
+
+		ctx := withLogAttrs(context.Background(), "queue_id", item.id)
+		job, err := submitToSPN(ctx, item.url, item.accessKey, item.secretKey, item.opts)
+		if err != nil {
+			job = SPNJob{URL: item.url, Status: "error", Error: err.Error()}
+		}
+		job.QueueID = item.id
+
+		q.mu.Lock()
+		q.jobs[item.id] = &job
+		if IsTerminal(job.Status) || job.JobID == "" {
+			q.finished[item.id] = time.Now()
+		}
+		q.mu.Unlock()
+
+		if !IsTerminal(job.Status) && job.JobID != "" {
+			go q.poll(item.id, job.JobID)
+		}
+	}
+}
+
+// poll follows an accepted SPN job until it reaches a terminal status,
+// keeping the stored job up to date
+func (q *spnQueue) poll(id, jobID string) {
+	ctx, cancel := context.WithTimeout(withLogAttrs(context.Background(), "queue_id", id), spnPollTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(spnPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logFor(ctx, "spn").Warn("gave up polling", "job_id", jobID, "after", spnPollTimeout)
+			q.mu.Lock()
+			q.finished[id] = time.Now()
+			q.mu.Unlock()
+			return
+		case <-ticker.C:
+		}
+
+		status, err := checkSPNStatus(ctx, jobID)
+		if err != nil {
+			logFor(ctx, "spn").Warn("poll failed", "job_id", jobID, "error", err)
+			continue
+		}
+
+		q.mu.Lock()
+		if job, ok := q.jobs[id]; ok {
+			job.Status = status.Status
+			job.Timestamp = status.Timestamp
+			job.Resources = status.Resources
+			job.Error = status.Error
+		}
+		if IsTerminal(status.Status) {
+			q.finished[id] = time.Now()
+		}
+		q.mu.Unlock()
+
+		if IsTerminal(status.Status) {
+			return
+		}
+	}
+}
+
+// evictLocked drops expired finished jobs, plus further finished jobs while
+// the store is full. Caller holds q.mu.
+func (q *spnQueue) evictLocked() {
+	for id, at := range q.finished {
+		if time.Since(at) > spnJobRetention || len(q.jobs) >= spnMaxJobs {
+			delete(q.jobs, id)
+			delete(q.finished, id)
+		}
+	}
+}
+
+func newQueueID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SPNJobsResponse is the response for GET /api/spn/jobs
+type SPNJobsResponse struct {
+	QueueDepth   int      `json:"queue_depth"`
+	Interval     string   `json:"interval"`      // Current spacing, including any backoff after 429s
+	BaseInterval string   `json:"base_interval"` // Config.SPNInterval, the spacing when SPN is healthy
+	Jobs         []SPNJob `json:"jobs"`
+}
+
+// SPNJobsHandler handles GET /api/spn/jobs and GET /api/spn/jobs?id=xxx
+func SPNJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id := r.URL.Query().Get("id"); id != "" {
+		job, ok := spnJobs.get(id)
+		if !ok {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+
+	json.NewEncoder(w).Encode(SPNJobsResponse{
+		QueueDepth:   spnJobs.depth(),
+		Interval:     spnLimiter.interval().String(),
+		BaseInterval: currentConfig().SPNInterval.String(),
+		Jobs:         spnJobs.list(),
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// scanProgress is the payload of a "progress" event
+type scanProgress struct {
+	Checked  int          `json:"checked"`
+	Total    int          `json:"total"`
+	Summary  *ScanSummary `json:"summary,omitempty"`  // Only on "done"
+	Revision int64        `json:"revision,omitempty"` // Revision scanned; only on "done"
+
+	Title          string `json:"title,omitempty"`           // Title scanned if MediaWiki resolved page elsewhere; only on "done"
+	RedirectedFrom string `json:"redirected_from,omitempty"` // Redirect followed to Title; only on "done"
+}
+
+// streamEvent is one Server-Sent Event: a name and a JSON payload
+type streamEvent struct {
+	name string
+	data any
+}
+
+// ScanStreamHandler handles GET /api/scan/stream?page=xxx. It streams the
+// scan as Server-Sent Events: a "result" event with each linkResult as it
+// completes, a "progress" event after each one, then "done" with the scan
+// summary (or "error"). Disconnecting cancels the scan.
+func ScanStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("page")) == "" {
+		http.Error(w, "page required", http.StatusBadRequest)
+		return
+	}
+	page, err := validatePageTitle(r.URL.Query().Get("page"))
+	if err != nil {
+		http.Error(w, "Invalid page title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	since, err := parseAddedSince(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oldid, err := parseOldID(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// The request context ends when the client goes away, which cancels
+	// the scan and unblocks any pending send below
+	ctx := r.Context()
+	events := make(chan streamEvent)
+	go func() {
+		defer close(events)
+		send := func(ev streamEvent) {
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		}
+		opts := scanOptions{archiveOnly: archiveOnlyRequested(r), addedSince: since, oldid: oldid}
+		opts.onResult = func(checked, total int, lr linkResult) {
+			send(streamEvent{"result", lr})
+			send(streamEvent{"progress", scanProgress{Checked: checked, Total: total}})
+		}
+		results, citationMap, err := scanPageWith(ctx, page, opts)
+		if err != nil {
+			send(streamEvent{"error", newErrorBody(err)})
+			return
+		}
+		summary := summarizeResults(results, citationMap)
+		done := scanProgress{Checked: len(results), Total: len(results), Summary: &summary, Revision: scannedRevision(citationMap)}
+		done.Title, done.RedirectedFrom = scannedTitle(citationMap, page)
+		send(streamEvent{"done", done})
+	}()
+
+	for ev := range events {
+		b, err := json.Marshal(ev.data)
+		if err != nil {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, b); err != nil {
+			// Client is gone; ctx is cancelled, so drain until the scan stops
+			continue
+		}
+		flusher.Flush()
+	}
+}
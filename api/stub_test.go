@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// testConfig is the config tests start from: live checks may reach the
+// loopback httptest servers on their random ports
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.AllowPrivateAddresses = true
+	cfg.AllowedPorts = []int{}
+	return cfg
+}
+
+// useConfig applies cfg for the rest of the test
+func useConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	SetConfig(cfg)
+	t.Cleanup(func() { SetConfig(DefaultConfig()) })
+}
+
+// hostRewriter sends every request to one test server, whatever host its
+// URL names, so code with fixed upstream endpoints (MediaWiki, Wayback)
+// runs against httptest. The Host header keeps the intended host.
+type hostRewriter struct {
+	target *url.URL
+	client *http.Client
+}
+
+func (d hostRewriter) Do(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Host = r.URL.Host
+	u := *r.URL
+	u.Scheme, u.Host = d.target.Scheme, d.target.Host
+	r.URL = &u
+	return d.client.Do(r)
+}
+
+// stubUpstream applies cfg and answers every outbound request with h; r.Host
+// names the host the request was meant for
+func stubUpstream(t *testing.T, cfg Config, h http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	useConfig(t, cfg)
+	target, _ := url.Parse(srv.URL)
+	httpClient = hostRewriter{target: target, client: srv.Client()}
+	return srv
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxTitleBytes is MediaWiki's limit on the UTF-8 length of a page title
+const maxTitleBytes = 255
+
+// titleIllegalChars can never appear in a MediaWiki title
+const titleIllegalChars = "<>[]|{}"
+
+// validatePageTitle checks a user-supplied page title and normalizes it the
+// way MediaWiki does: surrounding whitespace trimmed, any "#section"
+// fragment dropped, and runs of spaces/underscores collapsed to a single
+// underscore. Namespaced titles ("Talk:Foo") pass through unchanged.
+func validatePageTitle(raw string) (string, error) {
+	title := strings.TrimSpace(raw)
+	if i := strings.IndexByte(title, '#'); i >= 0 {
+		title = title[:i]
+	}
+
+	for _, r := range title {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("page title contains control characters")
+		}
+		if r == unicode.ReplacementChar {
+			return "", fmt.Errorf("page title is not valid UTF-8")
+		}
+		if strings.ContainsRune(titleIllegalChars, r) {
+			return "", fmt.Errorf("page title cannot contain %q", r)
+		}
+	}
+
+	title = strings.Join(strings.FieldsFunc(title, func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	}), "_")
+
+	if title == "" {
+		return "", fmt.Errorf("page title is empty")
+	}
+	if len(title) > maxTitleBytes {
+		return "", fmt.Errorf("page title is too long (%d bytes, max %d)", len(title), maxTitleBytes)
+	}
+	return title, nil
+}
+
+// scannedTitle returns the title a scan of page actually read, when
+// MediaWiki resolved page to a different one, and the redirect it followed
+// to get there, if any. Both are empty when page was scanned as given.
+func scannedTitle(cm *CitationMap, page string) (title, redirectedFrom string) {
+	if cm == nil || cm.Title == "" || normalizeTitle(cm.Title) == normalizeTitle(page) {
+		return "", ""
+	}
+	return cm.Title, cm.RedirectedFrom
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePageTitle(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"Blink-182", "Blink-182", false},
+		{"  Mark Hoppus  ", "Mark_Hoppus", false},
+		{"Enema of  the _State", "Enema_of_the_State", false},
+		{"Talk:Blink-182", "Talk:Blink-182", false},
+		{"Wikipedia:Link rot", "Wikipedia:Link_rot", false},
+		{"Blink-182#History", "Blink-182", false},
+		{"Zürich", "Zürich", false},
+		{"東京都", "東京都", false},
+		{"Foo\tBar", "", true},
+		{"Foo\x00Bar", "", true},
+		{"Foo\xffBar", "", true},
+		{"Foo[Bar]", "", true},
+		{"{{Cite web}}", "", true},
+		{"", "", true},
+		{"   ", "", true},
+		{"#History", "", true},
+		{strings.Repeat("a", maxTitleBytes), strings.Repeat("a", maxTitleBytes), false},
+		{strings.Repeat("a", maxTitleBytes+1), "", true},
+		{strings.Repeat("é", 128), "", true}, // 128 runes, 256 bytes
+	}
+	for _, tt := range tests {
+		got, err := validatePageTitle(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("validatePageTitle(%q) = %q, %v; want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
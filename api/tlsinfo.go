@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// certExpiryWarning flags certificates that expire within this window, so
+// cited sites about to break can be archived first
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// tlsInfo describes the leaf certificate an HTTPS link presented
+type tlsInfo struct {
+	Issuer  string
+	Expiry  time.Time
+	Problem string // Why verification failed ("expired", "self-signed", ...); empty if it passed
+}
+
+// expired reports whether the certificate is past its NotAfter date
+func (t *tlsInfo) expired(now time.Time) bool {
+	return !t.Expiry.IsZero() && now.After(t.Expiry)
+}
+
+// expiringSoon reports whether a still-valid certificate expires within
+// certExpiryWarning
+func (t *tlsInfo) expiringSoon(now time.Time) bool {
+	return !t.Expiry.IsZero() && !t.expired(now) && t.Expiry.Sub(now) < certExpiryWarning
+}
+
+// tlsFromState reads the leaf certificate of a completed handshake. It
+// returns nil for plain HTTP responses.
+func tlsFromState(state *tls.ConnectionState) *tlsInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return certInfo(state.PeerCertificates[0], "")
+}
+
+// tlsFromError extracts the rejected certificate from a failed handshake.
+// It returns nil if err is not a certificate verification failure.
+func tlsFromError(err error) *tlsInfo {
+	problem := certProblem(err)
+	if problem == "" {
+		return nil
+	}
+	var leaf *x509.Certificate
+	var verr *tls.CertificateVerificationError
+	var ierr x509.CertificateInvalidError
+	var aerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	switch {
+	case errors.As(err, &verr) && len(verr.UnverifiedCertificates) > 0:
+		leaf = verr.UnverifiedCertificates[0]
+	case errors.As(err, &ierr):
+		leaf = ierr.Cert
+	case errors.As(err, &aerr):
+		leaf = aerr.Cert
+	case errors.As(err, &herr):
+		leaf = herr.Certificate
+	}
+	if leaf == nil {
+		return &tlsInfo{Problem: problem}
+	}
+	return certInfo(leaf, problem)
+}
+
+// certProblem names the reason a certificate failed verification, each of
+// which calls for a different fix: "expired", "self-signed", "hostname
+// mismatch" or "untrusted CA". It returns "" for other errors.
+func certProblem(err error) string {
+	var ierr x509.CertificateInvalidError
+	var aerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	switch {
+	case errors.As(err, &ierr) && ierr.Reason == x509.Expired:
+		if ierr.Cert != nil && time.Now().Before(ierr.Cert.NotBefore) {
+			return "not yet valid"
+		}
+		return "expired"
+	case errors.As(err, &ierr):
+		return "invalid certificate"
+	case errors.As(err, &aerr):
+		if aerr.Cert != nil && bytes.Equal(aerr.Cert.RawIssuer, aerr.Cert.RawSubject) {
+			return "self-signed"
+		}
+		return "untrusted CA"
+	case errors.As(err, &herr):
+		return "hostname mismatch"
+	}
+	return ""
+}
+
+func certInfo(cert *x509.Certificate, problem string) *tlsInfo {
+	issuer := cert.Issuer.CommonName
+	if issuer == "" && len(cert.Issuer.Organization) > 0 {
+		issuer = cert.Issuer.Organization[0]
+	}
+	return &tlsInfo{Issuer: issuer, Expiry: cert.NotAfter, Problem: problem}
+}
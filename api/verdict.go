@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Verdicts combine the live check and archive state into one answer
+const (
+	verdictAlive           = "alive"            // Responds, and a good archive exists
+	verdictAliveUnarchived = "alive-unarchived" // Responds, but no archive yet
+	verdictDead            = "dead"             // Gone, and nothing archived
+	verdictDeadArchived    = "dead-archived"    // Gone, but a good archive exists
+	verdictBlocked         = "blocked"          // Server refused us (403/429); probably alive
+	verdictUnknown         = "unknown"          // Not enough signal to decide
+)
+
+// StatusRule overrides the verdict for live statuses From through To
+// (inclusive). Verdict is "alive", "dead", "blocked" or "unknown"; alive and
+// dead still become alive-unarchived and dead-archived by archive state.
+type StatusRule struct {
+	From, To int
+	Verdict  string
+}
+
+// statusRuleVerdicts are the verdicts a StatusRule may assign
+var statusRuleVerdicts = []string{verdictAlive, verdictDead, verdictBlocked, verdictUnknown}
+
+// ParseStatusVerdicts turns "401=dead,403=dead,418-499=unknown" into
+// Config.StatusVerdicts, validated with ValidateStatusVerdicts. An empty
+// list returns nil, i.e. the built-in policy.
+func ParseStatusVerdicts(list string) ([]StatusRule, error) {
+	var rules []StatusRule
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		codes, verdict, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid status rule %q: want code=verdict or from-to=verdict", s)
+		}
+		from, to, isRange := strings.Cut(codes, "-")
+		if !isRange {
+			to = from
+		}
+		r := StatusRule{Verdict: strings.ToLower(strings.TrimSpace(verdict))}
+		var err1, err2 error
+		r.From, err1 = strconv.Atoi(strings.TrimSpace(from))
+		r.To, err2 = strconv.Atoi(strings.TrimSpace(to))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid status rule %q: codes must be numbers", s)
+		}
+		rules = append(rules, r)
+	}
+	if err := ValidateStatusVerdicts(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateStatusVerdicts rejects rules outside 100-599, reversed ranges,
+// unknown verdicts and rules that overlap, since then which one applies
+// would depend on their order
+func ValidateStatusVerdicts(rules []StatusRule) error {
+	sorted := append([]StatusRule(nil), rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From < sorted[j].From })
+	for i, r := range sorted {
+		if r.From < 100 || r.To > 599 || r.From > r.To {
+			return fmt.Errorf("invalid status range %d-%d: want 100-599, low to high", r.From, r.To)
+		}
+		if !containsString(statusRuleVerdicts, r.Verdict) {
+			return fmt.Errorf("invalid verdict %q for %d-%d: want one of %s", r.Verdict, r.From, r.To, strings.Join(statusRuleVerdicts, ", "))
+		}
+		if i > 0 && r.From <= sorted[i-1].To {
+			return fmt.Errorf("status ranges %d-%d and %d-%d overlap", sorted[i-1].From, sorted[i-1].To, r.From, r.To)
+		}
+	}
+	return nil
+}
+
+// ruleVerdict returns the verdict a rule in rules assigns to code, if any
+func ruleVerdict(rules []StatusRule, code int, archived bool) (string, bool) {
+	for _, r := range rules {
+		if code < r.From || code > r.To {
+			continue
+		}
+		switch r.Verdict {
+		case verdictAlive:
+			if archived {
+				return verdictAlive, true
+			}
+			return verdictAliveUnarchived, true
+		case verdictDead:
+			return deadVerdict(archived), true
+		}
+		return r.Verdict, true
+	}
+	return "", false
+}
+
+// computeVerdict maps a live check result plus archive state to a verdict.
+// Config.StatusVerdicts decide for the statuses they cover; otherwise:
+//
+//	too many redirects (loop)              -> unknown
+//	2xx, 3xx                               -> alive / alive-unarchived
+//	403, 429                               -> blocked (archive state not considered)
+//	any other 4xx, 5xx                     -> dead / dead-archived
+//	no response, DNS failure or refused    -> dead / dead-archived
+//	no response, anything else (timeout,
+//	TLS error, skipped archive URL)        -> unknown
+//
+// A 3xx that was simply not followed (no Location, or redirects disabled)
+// still means the host answered, so it counts as alive. Hitting the redirect
+// cap doesn't: loops are often bot-only, so they stay unknown.
+func computeVerdict(code int, status string, archived bool) string {
+	if status == statusTooManyRedirects {
+		return verdictUnknown
+	}
+	if code != 0 {
+		if v, ok := ruleVerdict(currentConfig().StatusVerdicts, code, archived); ok {
+			return v
+		}
+	}
+	switch {
+	case code == http.StatusForbidden || code == http.StatusTooManyRequests:
+		return verdictBlocked
+	case code >= 200 && code < 400:
+		if archived {
+			return verdictAlive
+		}
+		return verdictAliveUnarchived
+	case code >= 400 && code < 600:
+		return deadVerdict(archived)
+	case code == 0 && isHardNetworkFailure(status):
+		return deadVerdict(archived)
+	}
+	return verdictUnknown
+}
+
+func deadVerdict(archived bool) string {
+	if archived {
+		return verdictDeadArchived
+	}
+	return verdictDead
+}
+
+// isHardNetworkFailure reports whether a classifyError status means the host
+// is definitely unreachable, as opposed to slow or misconfigured
+func isHardNetworkFailure(status string) bool {
+	return status == "DNS lookup failed" || status == "connection refused"
+}
+
+// ScanSummary counts a scan's results by verdict, so clients get the
+// article's overall reference health without tallying results themselves
+type ScanSummary struct {
+	TotalLinks     int `json:"total_links"`
+	Alive          int `json:"alive"`           // alive or alive-unarchived
+	Dead           int `json:"dead"`            // dead or dead-archived
+	Blocked        int `json:"blocked"`         // 403/429, probably alive
+	Archived       int `json:"archived"`        // Has an archive snapshot (archive URLs excluded)
+	Unarchived     int `json:"unarchived"`      // No snapshot found
+	AlreadyArchive int `json:"already_archive"` // The cited URL is itself an archive capture
+	Errors         int `json:"errors"`          // Checked, but no verdict (timeouts, TLS errors, ...)
+
+	DeadArchives int `json:"dead_archives,omitempty"` // Cited archive captures that no longer work (Config.CheckArchiveURLs)
+
+	CitationTemplates []TemplateUsage `json:"citation_templates,omitempty"` // With Config.FetchTemplates
+}
+
+// summarizeResults buckets results the same way computeVerdict does.
+// Archive-only results have no live verdict and only count toward the
+// archive totals. cm, if non-nil, supplies the citation templates.
+func summarizeResults(results []linkResult, cm *CitationMap) ScanSummary {
+	s := ScanSummary{TotalLinks: len(results)}
+	if cm != nil {
+		s.CitationTemplates = cm.Templates
+	}
+	for _, lr := range results {
+		if isArchiveURL(lr.URL) {
+			s.AlreadyArchive++
+			if lr.ArchiveLive != nil && !*lr.ArchiveLive {
+				s.DeadArchives++
+			}
+			continue
+		}
+		if lr.Archived {
+			s.Archived++
+		} else {
+			s.Unarchived++
+		}
+		switch lr.Verdict {
+		case verdictAlive, verdictAliveUnarchived:
+			s.Alive++
+		case verdictDead, verdictDeadArchived:
+			s.Dead++
+		case verdictBlocked:
+			s.Blocked++
+		default:
+			if !lr.LiveSkipped {
+				s.Errors++
+			}
+		}
+	}
+	return s
+}
@@ -1,25 +1,290 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	handler "example.com/iabot-go/api"
 )
 
 func main() {
+	// Every flag falls back to an IABOT_* environment variable, then to the
+	// built-in default
+	cfg := handler.DefaultConfig()
+	addr := flag.String("addr", envString("IABOT_ADDR", ":8081"), "listen address")
+	grace := flag.Duration("shutdown-grace", envDuration("IABOT_SHUTDOWN_GRACE", 30*time.Second), "how long to wait for in-flight requests on shutdown")
+
+	flag.StringVar(&cfg.LogFormat, "log-format", envString("IABOT_LOG_FORMAT", handler.LogFormatText), "log output: text or json")
+	logLevel := flag.String("log-level", envString("IABOT_LOG_LEVEL", "info"), "least severe level logged: debug, info, warn or error")
+	flag.IntVar(&cfg.Workers, "workers", envInt("IABOT_WORKERS", cfg.Workers), "links checked concurrently")
+	resultOrder := flag.String("result-order", envString("IABOT_RESULT_ORDER", handler.ResultOrderURL), `scan result order: "url" (alphabetical), "citation" (as cited in the article) or "completion" (as checks finish)`)
+	flag.IntVar(&cfg.MaxLinks, "max-links", envInt("IABOT_MAX_LINKS", cfg.MaxLinks), "most unique links checked per scan or batch")
+	flag.IntVar(&cfg.ClientRateLimit, "rate-limit", envInt("IABOT_RATE_LIMIT", cfg.ClientRateLimit), "scan/check/submit requests per minute per client IP (-1 disables)")
+	flag.IntVar(&cfg.ClientBurst, "rate-burst", envInt("IABOT_RATE_BURST", cfg.ClientBurst), "requests a client may make back to back before the rate limit applies")
+	flag.StringVar(&cfg.APIKey, "api-key", envString("IABOT_API_KEY", ""), "key required (as X-API-Key or a bearer token) by the Save Page Now endpoints")
+	basicAuth := flag.String("basic-auth", envString("IABOT_BASIC_AUTH", ""), `"user:password" accepted by the Save Page Now endpoints as HTTP basic auth`)
+	flag.BoolVar(&cfg.CompleteRequiresLiveArchive, "complete-requires-live-archive", envBool("IABOT_COMPLETE_REQUIRES_LIVE_ARCHIVE", false), "count a citation as complete only if its archive is verified to work (live-checks cited archive-urls)")
+	flag.BoolVar(&cfg.ProtectScans, "protect-scans", envBool("IABOT_PROTECT_SCANS", false), "require the -api-key or -basic-auth credentials on the scan endpoints too")
+	flag.StringVar(&cfg.AdminToken, "admin-token", envString("IABOT_ADMIN_TOKEN", ""), "bearer token for /admin/cache (empty disables it)")
+	flag.StringVar(&cfg.Contact, "contact", envString("IABOT_CONTACT", ""), "operator email or URL, included in the User-Agent")
+	flag.StringVar(&cfg.UserAgent, "user-agent", envString("IABOT_USER_AGENT", ""), "User-Agent for outbound requests (default built from -contact)")
+	flag.BoolVar(&cfg.FetchTemplates, "fetch-templates", envBool("IABOT_FETCH_TEMPLATES", false), "list the citation templates a page uses in scan summaries (enlarges every page fetch)")
+	flag.BoolVar(&cfg.DetectParked, "detect-parked", envBool("IABOT_DETECT_PARKED", false), "flag reachable links on parked domains (costs a DNS lookup and a GET per live link)")
+	flag.BoolVar(&cfg.InspectBody, "inspect-body", envBool("IABOT_INSPECT_BODY", false), "follow meta-refresh and JavaScript redirects on 2xx pages (costs a GET per live link)")
+	flag.BoolVar(&cfg.CheckArchiveURLs, "check-archive-urls", envBool("IABOT_CHECK_ARCHIVE_URLS", false), "live-check cited URLs that are archive captures themselves, reporting dead archives")
+	flag.BoolVar(&cfg.RespectRobots, "respect-robots", envBool("IABOT_RESPECT_ROBOTS", false), "skip links robots.txt disallows for us and honor its crawl-delay")
+	flag.StringVar(&cfg.Proxy, "proxy", envString("IABOT_PROXY", ""), "http://, https:// or socks5:// proxy for all outbound requests, credentials as user:pass@host (default from HTTP_PROXY, HTTPS_PROXY, ALL_PROXY)")
+	flag.StringVar(&cfg.NoProxy, "no-proxy", envString("IABOT_NO_PROXY", ""), "comma-separated hosts that bypass -proxy (default from NO_PROXY)")
+	flag.Int64Var(&cfg.MaxResponseBytes, "max-response-bytes", envInt64("IABOT_MAX_RESPONSE_BYTES", cfg.MaxResponseBytes), `largest response body read from any API or page; bigger ones fail with "response too large"`)
+	getFallbackStatuses := flag.String("get-fallback-statuses", envString("IABOT_GET_FALLBACK_STATUSES", "403,405,501"), `HEAD statuses that make a live check retry with GET, comma-separated, or "none"`)
+	flag.IntVar(&cfg.MaxRedirects, "max-redirects", envInt("IABOT_MAX_REDIRECTS", cfg.MaxRedirects), `redirects a live check follows before reporting "too many redirects" (-1 follows none)`)
+	flag.DurationVar(&cfg.LiveTimeout, "live-timeout", envDuration("IABOT_LIVE_TIMEOUT", cfg.LiveTimeout), "per-URL live check timeout, redirects and GET fallback included")
+	flag.DurationVar(&cfg.ConnectTimeout, "connect-timeout", envDuration("IABOT_CONNECT_TIMEOUT", cfg.ConnectTimeout), "connect plus TLS handshake timeout for outbound connections; unreachable hosts fail after this")
+	flag.DurationVar(&cfg.ResponseTimeout, "response-timeout", envDuration("IABOT_RESPONSE_TIMEOUT", cfg.ResponseTimeout), "how long a live check waits for response headers once connected")
+	flag.DurationVar(&cfg.WaybackTimeout, "wayback-timeout", envDuration("IABOT_WAYBACK_TIMEOUT", cfg.WaybackTimeout), "Wayback lookup timeout")
+	flag.StringVar(&cfg.ArchiveURLFlavor, "archive-url-flavor", envString("IABOT_ARCHIVE_URL_FLAVOR", cfg.ArchiveURLFlavor), `Wayback URL form: empty for the normal view, "id_" for the raw capture, "if_" without the banner`)
+	archiveProviders := flag.String("archive-providers", envString("IABOT_ARCHIVE_PROVIDERS", "wayback"), `archives consulted in order for existing snapshots, comma-separated: "wayback", "archive.today"`)
+	flag.BoolVar(&cfg.MementoFallback, "memento-fallback", envBool("IABOT_MEMENTO_FALLBACK", false), "ask the Memento aggregator for captures in other archives when the configured ones have none (one extra request per unarchived link)")
+	hostHeaders := flag.String("host-headers", envString("IABOT_HOST_HEADERS", ""), `JSON file of extra request headers per host, e.g. {"example.org": {"Authorization": "Bearer ..."}}`)
+	ignoredHosts := flag.String("ignored-hosts", envString("IABOT_IGNORED_HOSTS", strings.Join(cfg.IgnoredHosts, ",")), `hosts (with subdomains, optionally a path prefix) whose links are never extracted, comma-separated, or "none"`)
+	shortenerHosts := flag.String("shortener-hosts", envString("IABOT_SHORTENER_HOSTS", strings.Join(cfg.ShortenerHosts, ",")), `URL shorteners whose destination is reported as resolved_url, comma-separated, or "none"`)
+	flag.BoolVar(&cfg.ArchiveResolvedURL, "archive-resolved-url", envBool("IABOT_ARCHIVE_RESOLVED_URL", false), "look up and archive a shortener link's destination instead of the short URL")
+	trackingParams := flag.String("tracking-params", envString("IABOT_TRACKING_PARAMS", strings.Join(cfg.TrackingParams, ",")), `query parameters ignored when deduplicating URLs ("utm_*" matches by prefix), comma-separated, or "none"`)
+	flag.BoolVar(&cfg.StripTrailingSlash, "strip-trailing-slash", envBool("IABOT_STRIP_TRAILING_SLASH", false), "treat /page and /page/ as the same URL when deduplicating")
+	snapshotStatuses := flag.String("accepted-snapshot-statuses", envString("IABOT_ACCEPTED_SNAPSHOT_STATUSES", "200,203,206"), `captured HTTP statuses a Wayback snapshot needs to count as archived, comma-separated, or "any"`)
+	unknownSnapshotStatus := flag.String("unknown-snapshot-status", envString("IABOT_UNKNOWN_SNAPSHOT_STATUS", handler.UnknownSnapshotStatusCDX), `Wayback snapshots reported without a captured status: "cdx" (look the status up), "accept" or "reject"`)
+	flag.BoolVar(&cfg.TrustForwardedFor, "trust-forwarded-for", envBool("IABOT_TRUST_FORWARDED_FOR", false), "trust X-Forwarded-For / X-Real-IP from every peer; only when the server is reachable solely through a proxy that sets them")
+	allowedPorts := flag.String("allowed-ports", envString("IABOT_ALLOWED_PORTS", "80,443"), `ports live checks may connect to, comma-separated, or "any"`)
+	flag.BoolVar(&cfg.AllowPrivateAddresses, "allow-private-addresses", envBool("IABOT_ALLOW_PRIVATE_ADDRESSES", false), "let live checks reach loopback, private and link-local addresses (never on a public deployment)")
+	trustedProxies := flag.String("trusted-proxies", envString("IABOT_TRUSTED_PROXIES", ""), "reverse proxy addresses or CIDR ranges, comma-separated, whose X-Forwarded-For / X-Real-IP identify the client")
+	statusVerdicts := flag.String("status-verdicts", envString("IABOT_STATUS_VERDICTS", ""), `verdict overrides for live statuses, e.g. "401=dead,403=dead,500-599=unknown" (verdicts: alive, dead, blocked, unknown)`)
+	flag.DurationVar(&cfg.ArchiveStaleAfter, "archive-stale-after", envDuration("IABOT_ARCHIVE_STALE_AFTER", cfg.ArchiveStaleAfter), "age after which a snapshot is flagged archive_stale and worth re-capturing (negative disables)")
+	flag.DurationVar(&cfg.SPNTimeout, "spn-timeout", envDuration("IABOT_SPN_TIMEOUT", cfg.SPNTimeout), "Save Page Now submission timeout")
+	flag.DurationVar(&cfg.SPNStatusTimeout, "spn-status-timeout", envDuration("IABOT_SPN_STATUS_TIMEOUT", cfg.SPNStatusTimeout), "Save Page Now status check timeout")
+	flag.DurationVar(&cfg.SPNInterval, "spn-interval", envDuration("IABOT_SPN_INTERVAL", cfg.SPNInterval), "spacing between Save Page Now submissions")
+	flag.DurationVar(&cfg.SPNMaxWait, "spn-max-wait", envDuration("IABOT_SPN_MAX_WAIT", cfg.SPNMaxWait), `longest a "wait": true submission blocks for the capture to finish`)
+	flag.DurationVar(&cfg.SPNSkipIfArchivedWithin, "spn-skip-if-archived-within", envDuration("IABOT_SPN_SKIP_IF_ARCHIVED_WITHIN", cfg.SPNSkipIfArchivedWithin), `skip Save Page Now submissions of URLs with a snapshot newer than this, unless the request sets "force" (negative disables)`)
+	flag.IntVar(&cfg.SPNMaxBatch, "spn-max-batch", envInt("IABOT_SPN_MAX_BATCH", cfg.SPNMaxBatch), "most URLs accepted per Save Page Now request")
+	flag.DurationVar(&cfg.ResultCacheTTL, "result-cache-ttl", envDuration("IABOT_RESULT_CACHE_TTL", cfg.ResultCacheTTL), "how long finished scans are reused and cacheable (-1s disables; ?refresh=1 bypasses)")
+	flag.DurationVar(&cfg.ScanTimeout, "scan-timeout", envDuration("IABOT_SCAN_TIMEOUT", cfg.ScanTimeout), "whole page scan timeout")
+	flag.Parse()
+
+	if err := cfg.LogLevel.UnmarshalText([]byte(*logLevel)); err != nil {
+		log.Fatalf("-log-level: %v", err)
+	}
+	if *basicAuth != "" {
+		user, pass, ok := strings.Cut(*basicAuth, ":")
+		if !ok || user == "" {
+			log.Fatalf("-basic-auth: want user:password")
+		}
+		cfg.BasicAuthUser, cfg.BasicAuthPassword = user, pass
+	}
+	cfg.IgnoredHosts = splitList(*ignoredHosts)
+	cfg.TrackingParams = splitList(*trackingParams)
+	cfg.ShortenerHosts = splitList(*shortenerHosts)
+	if *hostHeaders != "" {
+		h, err := handler.LoadHostHeaders(*hostHeaders)
+		if err != nil {
+			log.Fatalf("-host-headers: %v", err)
+		}
+		cfg.HostHeaders = h
+	}
+	providers, err := handler.ParseArchiveProviders(*archiveProviders)
+	if err != nil {
+		log.Fatalf("-archive-providers: %v", err)
+	}
+	cfg.ArchiveProviders = providers
+	if cfg.AcceptedSnapshotStatuses, err = handler.ParseSnapshotStatuses(*snapshotStatuses); err != nil {
+		log.Fatalf("-accepted-snapshot-statuses: %v", err)
+	}
+	if cfg.UnknownSnapshotStatus, err = handler.ParseUnknownSnapshotStatus(*unknownSnapshotStatus); err != nil {
+		log.Fatalf("-unknown-snapshot-status: %v", err)
+	}
+	if cfg.GETFallbackStatuses, err = handler.ParseGETFallbackStatuses(*getFallbackStatuses); err != nil {
+		log.Fatalf("-get-fallback-statuses: %v", err)
+	}
+	if cfg.AllowedPorts, err = handler.ParseAllowedPorts(*allowedPorts); err != nil {
+		log.Fatalf("-allowed-ports: %v", err)
+	}
+	if cfg.TrustedProxies, err = handler.ParseTrustedProxies(*trustedProxies); err != nil {
+		log.Fatalf("-trusted-proxies: %v", err)
+	}
+	if cfg.StatusVerdicts, err = handler.ParseStatusVerdicts(*statusVerdicts); err != nil {
+		log.Fatalf("-status-verdicts: %v", err)
+	}
+	if cfg.ResultOrder, err = handler.ParseResultOrder(*resultOrder); err != nil {
+		log.Fatalf("-result-order: %v", err)
+	}
+
+	if cfg.Contact == "" && cfg.UserAgent == "" {
+		log.Printf("WARNING: no -contact (IABOT_CONTACT) configured. Wikimedia and the Internet Archive " +
+			"ask bots to include contact details in the User-Agent and may block requests without them.")
+	}
+	if cfg.APIKey == "" && cfg.BasicAuthUser == "" {
+		log.Printf("WARNING: no -api-key or -basic-auth configured; anyone who can reach the server " +
+			"can submit to Save Page Now on its credentials.")
+	} else if cfg.ProtectScans {
+		log.Printf("Scan endpoints require credentials (-protect-scans)")
+	}
+	handler.SetConfig(cfg)
+
 	mux := http.NewServeMux()
 
-	// Main page handler
-	mux.HandleFunc("/", handler.Handler)
+	// Health probes
+	mux.HandleFunc("/healthz", handler.HealthHandler)
+	mux.HandleFunc("/readyz", handler.ReadyHandler)
+
+	// API description
+	mux.HandleFunc("/api/openapi.json", handler.OpenAPIHandler)
+
+	// Prometheus metrics
+	mux.Handle("/metrics", handler.MetricsHandler())
+
+	// Cache inspection and flushing, behind -admin-token
+	mux.HandleFunc("/admin/cache", handler.AdminCacheHandler)
+
+	// Main page handler; a ?page= request runs a full scan, so it shares
+	// the scan endpoints' rate limit
+	mux.HandleFunc("/", handler.RequireAuthForScans(handler.RateLimit(handler.Handler)))
 
-	// SPN API endpoints
-	mux.HandleFunc("/api/spn/submit", handler.SPNSubmitHandler)
-	mux.HandleFunc("/api/spn/status", handler.SPNStatusHandler)
+	// JSON scan and bulk check endpoints. Anything that starts scans or
+	// submissions is rate limited per client, and needs credentials with
+	// -protect-scans.
+	mux.HandleFunc("/api/scan", handler.RequireAuthForScans(handler.RateLimit(handler.ScanAPIHandler)))
+	mux.HandleFunc("/api/scan/stream", handler.RequireAuthForScans(handler.RateLimit(handler.ScanStreamHandler)))
+	mux.HandleFunc("/api/scan/diff", handler.RequireAuthForScans(handler.RateLimit(handler.ScanDiffHandler)))
+	mux.HandleFunc("/api/scan/batch", handler.RequireAuthForScans(handler.RateLimit(handler.ScanBatchHandler)))
+	mux.HandleFunc("/api/needs-archive", handler.RequireAuthForScans(handler.RateLimit(handler.NeedsArchiveHandler)))
+	mux.HandleFunc("/api/check", handler.RequireAuthForScans(handler.RateLimit(handler.CheckHandler)))
+	mux.HandleFunc("/api/recheck", handler.RequireAuthForScans(handler.RateLimit(handler.RecheckHandler)))
+	mux.HandleFunc("/api/archive/preview", handler.RequireAuthForScans(handler.RateLimit(handler.ArchivePreviewHandler)))
 
-	addr := ":8081"
-	log.Printf("IABot-Go web listening on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatal(err)
+	// Citation parser preview; fetches nothing
+	mux.HandleFunc("/api/parse", handler.ParseHandler)
+
+	// SPN API endpoints. Submissions spend archive.org quota, so they need
+	// the -api-key or -basic-auth credentials whenever those are set.
+	mux.HandleFunc("/api/spn/submit", handler.RequireAuth(handler.RateLimit(handler.SPNSubmitHandler)))
+	mux.HandleFunc("/api/spn/status", handler.RequireAuthForScans(handler.SPNStatusHandler))
+	mux.HandleFunc("/api/spn/jobs", handler.RequireAuthForScans(handler.SPNJobsHandler))
+
+	// Scan + archive workflow
+	mux.HandleFunc("/api/scan-and-archive", handler.RequireAuth(handler.RateLimit(handler.ScanAndArchiveHandler)))
+
+	// Background page archiving for scheduled jobs
+	mux.HandleFunc("/api/archive-page", handler.RequireAuth(handler.RateLimit(handler.ArchivePageHandler)))
+	mux.HandleFunc("/api/archive-page/status", handler.RequireAuthForScans(handler.ArchivePageStatusHandler))
+
+	// Every request context derives from baseCtx, so cancelling it aborts
+	// in-flight scans once the grace period runs out
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	srv := &http.Server{
+		Addr:        *addr,
+		Handler:     handler.RequestID(mux),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	go func() {
+		log.Printf("IABot-Go web listening on %s", *addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+	stop()
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", *grace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Grace period expired, cancelling remaining requests: %v", err)
+		cancelBase()
+		srv.Close()
+	}
+	log.Printf("Shutdown complete")
+}
+
+// splitList splits a comma-separated flag value. "none" gives an empty,
+// non-nil list, which Config reads as "nothing" rather than the defaults.
+func splitList(s string) []string {
+	list := []string{}
+	if strings.TrimSpace(s) == "none" {
+		return list
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func envInt64(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return b
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
 	}
+	return d
 }